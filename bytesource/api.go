@@ -7,4 +7,5 @@ type BytesourceFileSettings struct {
 	UserHomePath       string
 	ExecPath           string
 	ConfigPaths        *Paths
+	Profile            string // an optional environment profile, whose config files overlay the base files
 }
@@ -24,21 +24,47 @@ func New_ConfigConnectYmlFiles(paths *Paths) *ConfigConnectYmlFiles {
 	}
 }
 
+// Constructor for ConfigConnectYmlFiles with an active environment profile
+func New_ConfigConnectYmlFilesProfile(paths *Paths, profile string) *ConfigConnectYmlFiles {
+	return &ConfigConnectYmlFiles{
+		paths:   paths,
+		profile: profile,
+	}
+}
+
 // A ConfigConnector that looks for files
+//
+// If a profile is active, then each path can also hold profile files
+// such as settings.stage.yml, which are provided as an extra scope
+// (project.stage) directly before the base file scope, so that they
+// overlay it.
 type ConfigConnectYmlFiles struct {
-	paths *Paths
+	paths   *Paths
+	profile string
+}
+
+// Get the active profile
+func (connect *ConfigConnectYmlFiles) Profile() string {
+	return connect.profile
 }
 
 func (connect *ConfigConnectYmlFiles) convertKeyToFileName(key string) string {
 	return strings.ToLower(key) + ".yml"
 }
 
+func (connect *ConfigConnectYmlFiles) convertKeyToProfileFileName(key string) string {
+	return strings.ToLower(key) + "." + strings.ToLower(connect.profile) + ".yml"
+}
+
 func (connect *ConfigConnectYmlFiles) findKey(key string) *Files {
 	files := Files{}
 	filename := connect.convertKeyToFileName(key)
 
 	for _, pathKey := range connect.paths.Order() {
 		pathRoot, _ := connect.paths.Get(pathKey)
+		if connect.profile != "" {
+			files.Add(pathKey+"."+connect.profile, pathRoot.FullPath(connect.convertKeyToProfileFileName(key)))
+		}
 		fileSource := pathRoot.FullPath(filename)
 		files.Add(pathKey, fileSource)
 	}
@@ -89,6 +115,9 @@ func (connect *ConfigConnectYmlFiles) List() []string {
 					if lastPeriod := strings.LastIndex(name, "."); lastPeriod > 0 {
 						name = name[:lastPeriod]
 					}
+					if strings.Contains(name, ".") {
+						continue // profile files are overlays, and not configs of their own
+					}
 					if _, alreadyFound := trackFound[name]; !alreadyFound {
 						files = append(files, name)
 						trackFound[name] = true
@@ -0,0 +1,299 @@
+package configwrapper
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * Conditions which can be written in yml config, and
+ * evaluated against the environment that radi runs in,
+ * such as the active profile or environment variables.
+ */
+
+// Something which can answer questions about the environment, for evaluating conditions
+type ConditionContext interface {
+	Profile() string
+	Getenv(name string) (string, bool)
+	UserName() string
+	FileExists(file string) bool
+	Setting(key string) (string, bool)
+	Now() time.Time
+	GitClean() (bool, bool)
+}
+
+// A yml condition, which holds only if all of its parts hold (an empty condition always holds)
+type Yml_Condition struct {
+	Profile string            `yaml:"Profile,omitempty"` // the active profile, or "" for no profile
+	Env     map[string]string `yaml:"Env,omitempty"`     // environment variable values, where "*" means any value
+	User    string            `yaml:"User,omitempty"`    // the OS user name
+	Exists  string            `yaml:"Exists,omitempty"`  // a file path which must exist, relative to the project root
+	Setting map[string]string `yaml:"Setting,omitempty"` // setting values, where "*" means any value
+	Weekday []string          `yaml:"Weekday,omitempty"` // days of the week, such as Mon or Friday
+	Time    string            `yaml:"Time,omitempty"`    // a local time of day window, such as 12:00-18:00
+	Git     string            `yaml:"Git,omitempty"`     // the git working tree state: clean or dirty
+
+	Not *Yml_Condition  `yaml:"Not,omitempty"` // holds if this condition does not
+	Any []Yml_Condition `yaml:"Any,omitempty"` // holds if any of these conditions do
+}
+
+// Does the condition hold in a context
+func (condition *Yml_Condition) Holds(context ConditionContext) bool {
+	if condition == nil {
+		return true
+	}
+
+	if condition.Profile != "" && condition.Profile != context.Profile() {
+		return false
+	}
+	for name, expected := range condition.Env {
+		if value, found := context.Getenv(name); !found || !conditionValueMatches(expected, value) {
+			return false
+		}
+	}
+	if condition.User != "" && condition.User != context.UserName() {
+		return false
+	}
+	if condition.Exists != "" && !context.FileExists(condition.Exists) {
+		return false
+	}
+	for key, expected := range condition.Setting {
+		if value, found := context.Setting(key); !found || !conditionValueMatches(expected, value) {
+			return false
+		}
+	}
+	if len(condition.Weekday) > 0 || condition.Time != "" {
+		now := context.Now()
+		if len(condition.Weekday) > 0 && !conditionWeekdayMatches(condition.Weekday, now.Weekday()) {
+			return false
+		}
+		if condition.Time != "" {
+			if inside, err := conditionTimeMatches(condition.Time, now); err != nil || !inside {
+				return false
+			}
+		}
+	}
+	if condition.Git != "" {
+		// an unknown state, such as outside of a git working tree, never matches
+		if clean, known := context.GitClean(); !known || (strings.ToLower(condition.Git) == "clean") != clean {
+			return false
+		}
+	}
+
+	if condition.Not != nil && condition.Not.Holds(context) {
+		return false
+	}
+	if len(condition.Any) > 0 {
+		for index := range condition.Any {
+			if condition.Any[index].Holds(context) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Get the setting keys that the condition reads, including those of its Not and Any conditions
+func (condition *Yml_Condition) SettingKeys() []string {
+	keys := []string{}
+	if condition == nil {
+		return keys
+	}
+	for key := range condition.Setting {
+		keys = append(keys, key)
+	}
+	keys = append(keys, condition.Not.SettingKeys()...)
+	for index := range condition.Any {
+		keys = append(keys, condition.Any[index].SettingKeys()...)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Check that the condition is well formed, such as its time window and weekdays
+func (condition *Yml_Condition) Validate() error {
+	if condition == nil {
+		return nil
+	}
+	for _, day := range condition.Weekday {
+		if _, err := conditionParseWeekday(day); err != nil {
+			return err
+		}
+	}
+	if condition.Time != "" {
+		if _, err := conditionTimeMatches(condition.Time, time.Time{}); err != nil {
+			return err
+		}
+	}
+	switch strings.ToLower(condition.Git) {
+	case "", "clean", "dirty":
+	default:
+		return errors.New("unknown Git state " + strconv.Quote(condition.Git) + ", expected clean or dirty")
+	}
+	if err := condition.Not.Validate(); err != nil {
+		return err
+	}
+	for index := range condition.Any {
+		if err := condition.Any[index].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Does a value match an expected condition value
+func conditionValueMatches(expected string, value string) bool {
+	return expected == "*" || expected == value
+}
+
+// Does a weekday match any of a list of day names
+func conditionWeekdayMatches(days []string, weekday time.Weekday) bool {
+	for _, day := range days {
+		if parsed, err := conditionParseWeekday(day); err == nil && parsed == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse a day name, such as Fri or friday
+func conditionParseWeekday(day string) (time.Weekday, error) {
+	lower := strings.ToLower(day)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if lower == name || (len(lower) >= 3 && strings.HasPrefix(name, lower)) {
+			return weekday, nil
+		}
+	}
+	return time.Sunday, errors.New("unknown Weekday " + strconv.Quote(day))
+}
+
+// Is the time of day of a time inside a window such as 12:00-18:00
+//
+// The start is inclusive and the end exclusive, and a window whose
+// end is before its start wraps past midnight, such as 22:00-06:00.
+func conditionTimeMatches(window string, now time.Time) (bool, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return false, errors.New("Time window " + strconv.Quote(window) + " must be start-end, such as 12:00-18:00")
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	if err != nil {
+		return false, errors.New("Time window " + strconv.Quote(window) + " has an invalid start: " + err.Error())
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err != nil {
+		return false, errors.New("Time window " + strconv.Quote(window) + " has an invalid end: " + err.Error())
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute, nil
+	}
+	return minute >= startMinute || minute < endMinute, nil
+}
+
+// Constructor for StandardConditionContext
+func New_StandardConditionContext(profile string, root string, settings SettingsConfigWrapper) *StandardConditionContext {
+	return &StandardConditionContext{
+		profile:  profile,
+		root:     root,
+		settings: settings,
+		clock:    time.Now,
+	}
+}
+
+// A ConditionContext for the running process, with a profile, project root and optional settings
+type StandardConditionContext struct {
+	profile  string
+	root     string
+	settings SettingsConfigWrapper
+	clock    func() time.Time
+
+	gitOnce  sync.Once // the git state is only read once for each context
+	gitClean bool
+	gitKnown bool
+}
+
+// Replace the clock used for time conditions, such as with a fixed time for tests
+func (context *StandardConditionContext) SetClock(clock func() time.Time) {
+	context.clock = clock
+}
+
+// Convert this to a ConditionContext
+func (context *StandardConditionContext) ConditionContext() ConditionContext {
+	return ConditionContext(context)
+}
+
+// The active profile
+func (context *StandardConditionContext) Profile() string {
+	return context.profile
+}
+
+// Get an environment variable
+func (context *StandardConditionContext) Getenv(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// The OS user name of the process
+func (context *StandardConditionContext) UserName() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return ""
+}
+
+// Does a file exist, where relative paths are relative to the project root
+func (context *StandardConditionContext) FileExists(file string) bool {
+	if !path.IsAbs(file) {
+		file = path.Join(context.root, file)
+	}
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+// Get the resolved value of a setting
+func (context *StandardConditionContext) Setting(key string) (string, bool) {
+	if context.settings == nil {
+		return "", false
+	}
+	snapshot := context.settings.Snapshot()
+	values, found := snapshot.Get(key)
+	if !found {
+		return "", false
+	}
+	_, value, found := values.Resolve(snapshot.Precedence())
+	return strings.TrimSpace(string(value)), found
+}
+
+// The current time, from the clock
+func (context *StandardConditionContext) Now() time.Time {
+	if context.clock == nil {
+		return time.Now()
+	}
+	return context.clock()
+}
+
+// Is the git working tree of the project root clean, and is the state known (not if it is not a git working tree)
+func (context *StandardConditionContext) GitClean() (bool, bool) {
+	context.gitOnce.Do(func() {
+		command := exec.Command("git", "status", "--porcelain")
+		command.Dir = context.root
+		if output, err := command.Output(); err == nil {
+			context.gitClean = len(strings.TrimSpace(string(output))) == 0
+			context.gitKnown = true
+		}
+	})
+	return context.gitClean, context.gitKnown
+}
@@ -0,0 +1,108 @@
+package configwrapper
+
+import (
+	"errors"
+	"strings"
+)
+
+/**
+ * Dependency ordering for project components, and for
+ * the implementations inside a single builder, so that
+ * things like config are activated before what needs it.
+ */
+
+// Something which requires and provides named capabilities, such as "config"
+type ProjectDependency struct {
+	Id       string
+	Requires []string
+	Provides []string
+}
+
+// A builder which declares what each of its implementations requires,
+// such as a setting implementation which requires config
+type ProjectRequirementsProvider interface {
+	ImplementationRequires(implementation string) []string
+}
+
+// Sort dependencies so that every item comes after the items that provide what it requires
+//
+// Items keep their original order where dependencies allow it.  The
+// available capabilities are treated as already provided.  An error is
+// returned if something required is provided by nothing, or if items
+// require each other in a cycle.
+func SortProjectDependencies(items []ProjectDependency, available []string) ([]string, error) {
+	providers := map[string][]int{}
+	for index, item := range items {
+		for _, provides := range item.Provides {
+			providers[provides] = append(providers[provides], index)
+		}
+	}
+	provided := map[string]bool{}
+	for _, capability := range available {
+		provided[capability] = true
+	}
+
+	// each item waits for every provider of each thing it requires
+	waitsFor := make([][]int, len(items))
+	for index, item := range items {
+		for _, requires := range item.Requires {
+			itemProviders := []int{}
+			for _, provider := range providers[requires] {
+				if provider != index {
+					itemProviders = append(itemProviders, provider)
+				}
+			}
+			if len(itemProviders) == 0 {
+				if provided[requires] || projectDependencyProvides(item, requires) {
+					continue
+				}
+				return nil, errors.New("Project component " + item.Id + " requires " + requires + ", which no component provides")
+			}
+			waitsFor[index] = append(waitsFor[index], itemProviders...)
+		}
+	}
+
+	sorted := []string{}
+	done := make([]bool, len(items))
+	for len(sorted) < len(items) {
+		progress := false
+		for index, item := range items {
+			if done[index] {
+				continue
+			}
+			ready := true
+			for _, provider := range waitsFor[index] {
+				if !done[provider] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				done[index] = true
+				sorted = append(sorted, item.Id)
+				progress = true
+				break // restart, so that earlier items keep priority
+			}
+		}
+		if !progress {
+			cycle := []string{}
+			for index, item := range items {
+				if !done[index] {
+					cycle = append(cycle, item.Id)
+				}
+			}
+			return nil, errors.New("Project components have a dependency cycle between: " + strings.Join(cycle, ", "))
+		}
+	}
+	return sorted, nil
+}
+
+// Does an item provide a capability itself
+func projectDependencyProvides(item ProjectDependency, capability string) bool {
+	for _, provides := range item.Provides {
+		if provides == capability {
+			return true
+		}
+	}
+	return false
+}
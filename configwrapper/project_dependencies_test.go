@@ -0,0 +1,108 @@
+package configwrapper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortProjectDependencies(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []ProjectDependency
+		available []string
+		sorted    []string
+		err       bool
+	}{
+		{
+			name:   "no items",
+			items:  []ProjectDependency{},
+			sorted: []string{},
+		},
+		{
+			name: "no requirements keeps the order",
+			items: []ProjectDependency{
+				{Id: "b", Provides: []string{"setting"}},
+				{Id: "a", Provides: []string{"config"}},
+			},
+			sorted: []string{"b", "a"},
+		},
+		{
+			name: "provider moves first",
+			items: []ProjectDependency{
+				{Id: "setting", Requires: []string{"config"}, Provides: []string{"setting"}},
+				{Id: "config", Provides: []string{"config"}},
+			},
+			sorted: []string{"config", "setting"},
+		},
+		{
+			name: "chain of requirements",
+			items: []ProjectDependency{
+				{Id: "project", Requires: []string{"setting"}, Provides: []string{"project"}},
+				{Id: "setting", Requires: []string{"config"}, Provides: []string{"setting"}},
+				{Id: "config", Provides: []string{"config"}},
+			},
+			sorted: []string{"config", "setting", "project"},
+		},
+		{
+			name: "earlier items keep priority",
+			items: []ProjectDependency{
+				{Id: "security", Requires: []string{"config"}, Provides: []string{"security"}},
+				{Id: "orchestrate", Provides: []string{"orchestrate"}},
+				{Id: "config", Provides: []string{"config"}},
+			},
+			sorted: []string{"orchestrate", "config", "security"},
+		},
+		{
+			name: "waits for every provider",
+			items: []ProjectDependency{
+				{Id: "setting", Requires: []string{"config"}, Provides: []string{"setting"}},
+				{Id: "config-local", Provides: []string{"config"}},
+				{Id: "config-remote", Provides: []string{"config"}},
+			},
+			sorted: []string{"config-local", "config-remote", "setting"},
+		},
+		{
+			name: "available requirement",
+			items: []ProjectDependency{
+				{Id: "setting", Requires: []string{"config"}, Provides: []string{"setting"}},
+			},
+			available: []string{"config"},
+			sorted:    []string{"setting"},
+		},
+		{
+			name: "requirement provided by the item itself",
+			items: []ProjectDependency{
+				{Id: "local", Requires: []string{"config"}, Provides: []string{"config", "setting"}},
+			},
+			sorted: []string{"local"},
+		},
+		{
+			name: "missing requirement",
+			items: []ProjectDependency{
+				{Id: "setting", Requires: []string{"config"}, Provides: []string{"setting"}},
+			},
+			err: true,
+		},
+		{
+			name: "cycle",
+			items: []ProjectDependency{
+				{Id: "a", Requires: []string{"b"}, Provides: []string{"a"}},
+				{Id: "b", Requires: []string{"a"}, Provides: []string{"b"}},
+				{Id: "c", Provides: []string{"c"}},
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorted, err := SortProjectDependencies(test.items, test.available)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if !test.err && !reflect.DeepEqual(sorted, test.sorted) {
+				t.Errorf("expected %v, got %v", test.sorted, sorted)
+			}
+		})
+	}
+}
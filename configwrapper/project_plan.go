@@ -0,0 +1,478 @@
+package configwrapper
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	api_builder "github.com/wunderkraut/radi-api/builder"
+	api_operation "github.com/wunderkraut/radi-api/operation"
+	api_config "github.com/wunderkraut/radi-api/operation/config"
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_result "github.com/wunderkraut/radi-api/result"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+)
+
+/**
+ * Project plans, which describe what activating the
+ * project components would register, without activating
+ * them in the running API.
+ */
+
+const (
+	// The Config key for the last applied project plan
+	CONFIG_KEY_PROJECT_PLAN = "project-plan"
+
+	// Operation id for the plan operation
+	OPERATION_ID_PROJECT_PLAN = "project.plan"
+
+	// Properties for the plan operation
+	OPERATION_PROPERTY_PROJECT_PLAN_APPLY  = "project.plan.apply"
+	OPERATION_PROPERTY_PROJECT_PLAN_REPORT = "project.plan.report"
+	OPERATION_PROPERTY_PROJECT_PLAN_DIFF   = "project.plan.diff"
+)
+
+// Make a new, unactivated builder
+type ProjectBuilderFactory func() api_builder.Builder
+
+// A builder which can describe its implementations, for planning
+type ProjectPlanBuilder interface {
+	KnownImplementations() []string
+	ImplementationHandlers(implementation string) []string
+}
+
+// A builder which can report the operations that activating implementations
+// would add, without side effects such as starting services, for planning
+type ProjectPlanDryRunBuilder interface {
+	DryActivate(implementations api_builder.Implementations, settingsProvider api_builder.SettingsProvider) ([]string, error)
+}
+
+// What activating the project components would register
+type ProjectPlan struct {
+	Components []ProjectPlanComponent `yaml:"Components"`
+}
+
+// What activating a single component would register
+type ProjectPlanComponent struct {
+	Key             string                      `yaml:"Key"`
+	Type            string                      `yaml:"Type"`
+	Unknown         bool                        `yaml:"Unknown,omitempty"` // no builder is known for the component type
+	Implementations []ProjectPlanImplementation `yaml:"Implementations"`
+}
+
+// What activating a single implementation would register
+type ProjectPlanImplementation struct {
+	Implementation string   `yaml:"Implementation"`
+	Unknown        bool     `yaml:"Unknown,omitempty"`   // the builder does not provide the implementation
+	Unplanned      bool     `yaml:"Unplanned,omitempty"` // the builder can't activate without side effects, so its operations are not known
+	Handlers       []string `yaml:"Handlers,omitempty"`
+	Operations     []string `yaml:"Operations,omitempty"`
+	Overrides      []string `yaml:"Overrides,omitempty"` // operations which replace ones from earlier implementations
+}
+
+// Make a plan for project components, using builder factories for each component type
+//
+// The implementations of each component are dry activated in a fresh
+// builder, in the order that their requirements need, adding one at a
+// time so that new operations can be attributed to implementations.
+// Builders which can't dry activate are only described.
+func New_ProjectPlan(components api_builder.ProjectConfigWrapper, factories map[string]ProjectBuilderFactory) ProjectPlan {
+	plan := ProjectPlan{Components: []ProjectPlanComponent{}}
+	registered := map[string]string{} // operation id to the component/implementation that registered it
+
+	for _, key := range components.List() {
+		component, _ := components.Get(key)
+		planComponent := ProjectPlanComponent{Key: key, Type: component.Type(), Implementations: []ProjectPlanImplementation{}}
+
+		factory, found := factories[component.Type()]
+		if !found {
+			planComponent.Unknown = true
+			for _, implementation := range component.Implementations().Order() {
+				planComponent.Implementations = append(planComponent.Implementations, ProjectPlanImplementation{Implementation: implementation, Unknown: true})
+			}
+			plan.Components = append(plan.Components, planComponent)
+			continue
+		}
+
+		builder := factory()
+		var known []string
+		planBuilder, describes := builder.(ProjectPlanBuilder)
+		if describes {
+			known = planBuilder.KnownImplementations()
+		}
+		dryRunBuilder, dryRuns := builder.(ProjectPlanDryRunBuilder)
+
+		activated := []string{}
+		existing := map[string]bool{}
+		for _, implementation := range projectPlanImplementationOrder(builder, component.Implementations().Order()) {
+			planImplementation := ProjectPlanImplementation{Implementation: implementation}
+			if describes && !projectComponentsContains(known, implementation) {
+				planImplementation.Unknown = true
+				planComponent.Implementations = append(planComponent.Implementations, planImplementation)
+				continue
+			}
+			if describes {
+				planImplementation.Handlers = planBuilder.ImplementationHandlers(implementation)
+			}
+			if !dryRuns {
+				planImplementation.Unplanned = true
+				planComponent.Implementations = append(planComponent.Implementations, planImplementation)
+				continue
+			}
+
+			activated = append(activated, implementation)
+			operations, err := dryRunBuilder.DryActivate(*api_builder.New_Implementations(activated), component.SettingsProvider())
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{"component": key, "implementation": implementation}).Warn("Implementation failed to dry activate while planning")
+			}
+
+			for _, id := range operations {
+				if existing[id] {
+					continue
+				}
+				existing[id] = true
+				planImplementation.Operations = append(planImplementation.Operations, id)
+				if _, overridden := registered[id]; overridden {
+					planImplementation.Overrides = append(planImplementation.Overrides, id)
+				}
+				registered[id] = key + "/" + implementation
+			}
+			planComponent.Implementations = append(planComponent.Implementations, planImplementation)
+		}
+
+		plan.Components = append(plan.Components, planComponent)
+	}
+
+	return plan
+}
+
+// Order the implementations of a component by what the builder declares that they require
+//
+// Requirements that the component does not provide are left to other
+// components.  The original order is kept if the builder declares no
+// requirements, or if they can't be ordered.
+func projectPlanImplementationOrder(builder interface{}, implementations []string) []string {
+	provider, ok := builder.(ProjectRequirementsProvider)
+	if !ok {
+		return implementations
+	}
+
+	dependencies := []ProjectDependency{}
+	available := []string{}
+	for _, implementation := range implementations {
+		requires := provider.ImplementationRequires(implementation)
+		for _, required := range requires {
+			if !projectComponentsContains(implementations, required) {
+				available = append(available, required)
+			}
+		}
+		dependencies = append(dependencies, ProjectDependency{Id: implementation, Requires: requires, Provides: []string{implementation}})
+	}
+
+	sorted, err := SortProjectDependencies(dependencies, available)
+	if err != nil {
+		log.WithError(err).Warn("Could not order implementations while planning")
+		return implementations
+	}
+	return sorted
+}
+
+// Describe the plan as readable lines
+func (plan ProjectPlan) Report() []string {
+	lines := []string{}
+	for _, component := range plan.Components {
+		if component.Unknown {
+			lines = append(lines, component.Key+" ("+component.Type+"): unknown builder type")
+			continue
+		}
+		lines = append(lines, component.Key+" ("+component.Type+")")
+		for _, implementation := range component.Implementations {
+			if implementation.Unknown {
+				lines = append(lines, "  "+implementation.Implementation+": unknown implementation")
+				continue
+			}
+			if implementation.Unplanned {
+				lines = append(lines, "  "+implementation.Implementation+": handlers ["+strings.Join(implementation.Handlers, ", ")+"] operations unknown, as the builder can't dry activate")
+				continue
+			}
+			lines = append(lines, "  "+implementation.Implementation+": handlers ["+strings.Join(implementation.Handlers, ", ")+"] operations ["+strings.Join(implementation.Operations, ", ")+"]")
+			for _, override := range implementation.Overrides {
+				lines = append(lines, "    overrides "+override)
+			}
+		}
+	}
+	return lines
+}
+
+// Describe the differences from an earlier plan, as readable lines
+func (plan ProjectPlan) Diff(earlier ProjectPlan) []string {
+	before := earlier.registrations()
+	after := plan.registrations()
+
+	keys := []string{}
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, found := before[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, key := range keys {
+		beforeSource, inBefore := before[key]
+		afterSource, inAfter := after[key]
+		switch {
+		case !inBefore:
+			lines = append(lines, "+ "+key+" ("+afterSource+")")
+		case !inAfter:
+			lines = append(lines, "- "+key+" ("+beforeSource+")")
+		case beforeSource != afterSource:
+			lines = append(lines, "~ "+key+" ("+beforeSource+" -> "+afterSource+")")
+		}
+	}
+	return lines
+}
+
+// Map everything the plan registers to the component/implementation that registers it
+func (plan ProjectPlan) registrations() map[string]string {
+	registrations := map[string]string{}
+	for _, component := range plan.Components {
+		for _, implementation := range component.Implementations {
+			source := component.Key + "/" + implementation.Implementation
+			if implementation.Unknown {
+				registrations["implementation "+source] = "unknown"
+				continue
+			}
+			for _, handler := range implementation.Handlers {
+				registrations["handler "+handler] = source
+			}
+			for _, operation := range implementation.Operations {
+				registrations["operation "+operation] = source
+			}
+		}
+	}
+	return registrations
+}
+
+// Load the last applied plan from config, which is empty if there is none
+func LoadProjectPlan(wrapper api_config.ConfigWrapper, scope string) (ProjectPlan, error) {
+	plan := ProjectPlan{Components: []ProjectPlanComponent{}}
+	sources, err := wrapper.Get(CONFIG_KEY_PROJECT_PLAN)
+	if err != nil {
+		return plan, nil // no plan has been applied
+	}
+	if source, found := sources.Get(scope); found {
+		err = yaml.Unmarshal(source, &plan)
+	}
+	return plan, err
+}
+
+// Save a plan to config, as the last applied plan
+func SaveProjectPlan(wrapper api_config.ConfigWrapper, scope string, plan ProjectPlan) error {
+	source, err := yaml.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	scopedValues := api_config.ConfigScopedValues{}
+	scopedValues.Set(scope, api_config.ConfigScopedValue(source))
+	return wrapper.Set(CONFIG_KEY_PROJECT_PLAN, scopedValues)
+}
+
+// A Project Plan operation, which reports what the project components would register
+type ProjectPlanOperation struct {
+	Components *ProjectComponentsConfigWrapperYaml
+	Config     api_config.ConfigWrapper
+	Factories  map[string]ProjectBuilderFactory
+}
+
+// Id the operation
+func (plan ProjectPlanOperation) Id() string {
+	return OPERATION_ID_PROJECT_PLAN
+}
+
+// Label the operation
+func (plan ProjectPlanOperation) Label() string {
+	return "Plan project"
+}
+
+// Description for the operation
+func (plan ProjectPlanOperation) Description() string {
+	return "Report which handlers and operations the project components would register, and how that differs from the last applied plan."
+}
+
+// Help text for the operation
+func (plan ProjectPlanOperation) Help() string {
+	return "Components are dry activated in fresh builders, so the running API is not changed and nothing is started.  Implementations that a builder does not provide, and components of unknown builder types, are reported.  Use the apply property to store the plan as the last applied plan."
+}
+
+// Usage for the operation
+func (plan ProjectPlanOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+// Validate the operation
+func (plan ProjectPlanOperation) Validate() api_result.Result {
+	if plan.Components == nil {
+		res := api_result.New_StandardResult()
+		res.MarkFailed()
+		res.AddError(errors.New("Project plan operation has no project components"))
+		res.MarkFinished()
+		return res.Result()
+	}
+	return api_result.MakeSuccessfulResult()
+}
+
+// Get properties
+func (plan ProjectPlanOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&ProjectPlanApplyProperty{}))
+	props.Add(api_property.Property(&ProjectPlanReportProperty{}))
+	props.Add(api_property.Property(&ProjectPlanDiffProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (plan ProjectPlanOperation) Exec(props api_property.Properties) api_result.Result {
+	res := api_result.New_StandardResult()
+
+	applyProp, _ := props.Get(OPERATION_PROPERTY_PROJECT_PLAN_APPLY)
+	reportProp, _ := props.Get(OPERATION_PROPERTY_PROJECT_PLAN_REPORT)
+	diffProp, _ := props.Get(OPERATION_PROPERTY_PROJECT_PLAN_DIFF)
+
+	for builderType, factory := range plan.Factories {
+		builder := factory()
+		plan.Components.RegisterBuilderSchema(builderType, builder)
+		plan.Components.RegisterBuilderRequires(builderType, builder)
+	}
+
+	current := New_ProjectPlan(plan.Components.ProjectConfigWrapper(), plan.Factories)
+	reportProp.Set(current.Report())
+
+	scope := plan.Components.DefaultScope()
+	if earlier, err := LoadProjectPlan(plan.Config, scope); err == nil {
+		diffProp.Set(current.Diff(earlier))
+	} else {
+		log.WithError(err).Warn("Could not read the last applied project plan")
+		diffProp.Set(current.Diff(ProjectPlan{}))
+	}
+
+	res.MarkSuccess()
+	if apply, _ := applyProp.Get().(bool); apply {
+		if err := SaveProjectPlan(plan.Config, scope, current); err != nil {
+			res.MarkFailed()
+			res.AddError(err)
+		}
+	}
+
+	res.MarkFinished()
+
+	return res.Result()
+}
+
+/**
+ * Properties
+ */
+
+// Property for storing the plan as the last applied plan
+type ProjectPlanApplyProperty struct {
+	api_property.BoolProperty
+}
+
+// Id for the Property
+func (apply *ProjectPlanApplyProperty) Id() string {
+	return OPERATION_PROPERTY_PROJECT_PLAN_APPLY
+}
+
+// Label for the Property
+func (apply *ProjectPlanApplyProperty) Label() string {
+	return "Apply plan"
+}
+
+// Description for the Property
+func (apply *ProjectPlanApplyProperty) Description() string {
+	return "Store this plan as the last applied plan, for later diffs."
+}
+
+// Is the Property internal only
+func (apply *ProjectPlanApplyProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (apply *ProjectPlanApplyProperty) Copy() api_property.Property {
+	prop := &ProjectPlanApplyProperty{}
+	prop.Set(apply.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the plan report
+type ProjectPlanReportProperty struct {
+	api_property.StringSliceProperty
+}
+
+// Id for the Property
+func (report *ProjectPlanReportProperty) Id() string {
+	return OPERATION_PROPERTY_PROJECT_PLAN_REPORT
+}
+
+// Label for the Property
+func (report *ProjectPlanReportProperty) Label() string {
+	return "Plan"
+}
+
+// Description for the Property
+func (report *ProjectPlanReportProperty) Description() string {
+	return "What each project component implementation would register."
+}
+
+// Is the Property internal only
+func (report *ProjectPlanReportProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (report *ProjectPlanReportProperty) Copy() api_property.Property {
+	prop := &ProjectPlanReportProperty{}
+	prop.Set(report.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the differences from the last applied plan
+type ProjectPlanDiffProperty struct {
+	api_property.StringSliceProperty
+}
+
+// Id for the Property
+func (diff *ProjectPlanDiffProperty) Id() string {
+	return OPERATION_PROPERTY_PROJECT_PLAN_DIFF
+}
+
+// Label for the Property
+func (diff *ProjectPlanDiffProperty) Label() string {
+	return "Plan changes"
+}
+
+// Description for the Property
+func (diff *ProjectPlanDiffProperty) Description() string {
+	return "Handlers and operations added (+), removed (-) or moved (~) since the last applied plan."
+}
+
+// Is the Property internal only
+func (diff *ProjectPlanDiffProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (diff *ProjectPlanDiffProperty) Copy() api_property.Property {
+	prop := &ProjectPlanDiffProperty{}
+	prop.Set(diff.Get())
+	return api_property.Property(prop)
+}
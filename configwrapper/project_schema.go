@@ -0,0 +1,234 @@
+package configwrapper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	api_builder "github.com/wunderkraut/radi-api/builder"
+)
+
+/**
+ * Schemas for the Settings block of project components,
+ * which builders publish so that typos and bad values in
+ * handler settings are reported instead of ignored.
+ */
+
+// The schema for the Settings block of project components of a single builder type
+type ProjectSettingsSchema struct {
+	Settings map[string]SettingSchema `yaml:"Settings"`
+}
+
+// A builder which publishes a schema for its component settings
+type ProjectSettingsSchemaProvider interface {
+	ProjectSettingsSchema() ProjectSettingsSchema
+}
+
+// Register the component settings schema for a builder type
+//
+// Loaded components are discarded, so that they are loaded and
+// validated again when next used.
+func (projectComponents *ProjectComponentsConfigWrapperYaml) RegisterSettingsSchema(builderType string, schema ProjectSettingsSchema) {
+	if projectComponents.schemas == nil {
+		projectComponents.schemas = map[string]ProjectSettingsSchema{}
+	}
+	projectComponents.schemas[builderType] = schema
+	projectComponents.components = api_builder.ProjectComponents{}
+}
+
+// Register the schema of a builder, if it publishes one, returning whether it did
+func (projectComponents *ProjectComponentsConfigWrapperYaml) RegisterBuilderSchema(builderType string, builder interface{}) bool {
+	if provider, ok := builder.(ProjectSettingsSchemaProvider); ok {
+		projectComponents.RegisterSettingsSchema(builderType, provider.ProjectSettingsSchema())
+		return true
+	}
+	return false
+}
+
+// Return the component settings issues found when the components were loaded
+func (projectComponents *ProjectComponentsConfigWrapperYaml) Issues() []SettingLintIssue {
+	projectComponents.safe()
+	return projectComponents.issues
+}
+
+// Validate the raw settings of a single component against a schema
+//
+// The component is the index'th item of the Components list in the
+// scope source lines, which are used to report the line of each issue.
+func projectComponentSettingsIssues(scope string, lines []string, index int, name string, raw interface{}, schema ProjectSettingsSchema) []SettingLintIssue {
+	issues := []SettingLintIssue{}
+	file := settingScopeFileName(CONFIG_KEY_BUILDER, scope)
+	issue := func(severity string, field string, message string) {
+		key := name
+		if field != "" {
+			key += ".Settings." + field
+		}
+		issues = append(issues, SettingLintIssue{Severity: severity, Scope: scope, File: file, Line: ymlTool_FindComponentSettingLine(lines, index, field), Key: key, Message: message})
+	}
+
+	fields := map[string]interface{}{}
+	fieldOrder := []string{}
+	switch settings := raw.(type) {
+	case nil:
+		return issues
+	case yaml.MapSlice:
+		for _, item := range settings {
+			field := fmt.Sprint(item.Key)
+			fields[field] = item.Value
+			fieldOrder = append(fieldOrder, field)
+		}
+	default:
+		issue(SETTING_LINT_ERROR, "", "component Settings must be a map")
+		return issues
+	}
+
+	for _, field := range fieldOrder {
+		fieldSchema, known := schema.Settings[field]
+		if !known {
+			issue(SETTING_LINT_WARNING, field, "field is not in the settings schema for this component type")
+			continue
+		}
+
+		value := fields[field]
+		switch strings.ToLower(fieldSchema.Type) {
+		case "list":
+			if _, ok := value.([]interface{}); !ok {
+				issue(SETTING_LINT_ERROR, field, "value is not a list")
+			}
+		case "map":
+			if _, ok := value.(yaml.MapSlice); !ok {
+				issue(SETTING_LINT_ERROR, field, "value is not a map")
+			}
+		default:
+			switch value.(type) {
+			case yaml.MapSlice, []interface{}:
+				issue(SETTING_LINT_ERROR, field, "value is not a scalar")
+			default:
+				if err := settingCheckType(fieldSchema.Type, fmt.Sprint(value)); err != nil {
+					issue(SETTING_LINT_ERROR, field, err.Error())
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+// Log component settings issues, so that they are seen when loading
+func projectComponentLogIssues(issues []SettingLintIssue) {
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	for _, issue := range issues {
+		if issue.Severity == SETTING_LINT_ERROR {
+			log.Error("Project component settings: " + issue.String())
+		} else {
+			log.Warn("Project component settings: " + issue.String())
+		}
+	}
+}
+
+// Find the 1 based line of a component settings field in yml source lines (0 if not found)
+//
+// If the field is not found, then the line of the component Settings
+// is returned, or failing that the line of the component itself.
+func ymlTool_FindComponentSettingLine(lines []string, index int, field string) int {
+	start, end := ymlTool_FindComponentLines(lines, index)
+	if start < 0 {
+		return 0
+	}
+
+	settingsLine, settingsIndent := -1, 0
+	for line := start; line < end; line++ {
+		trimmed := strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(lines[line], " "), "- "), " ")
+		if strings.HasPrefix(trimmed, "Settings:") {
+			settingsLine, settingsIndent = line, len(lines[line])-len(trimmed)
+			break
+		}
+	}
+	if settingsLine < 0 {
+		return start + 1
+	}
+	if field == "" {
+		return settingsLine + 1
+	}
+
+	for line := settingsLine + 1; line < end; line++ {
+		trimmed := strings.TrimLeft(lines[line], " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if len(lines[line])-len(trimmed) <= settingsIndent {
+			break // past the end of the settings block
+		}
+		if strings.HasPrefix(trimmed, field+":") {
+			return line + 1
+		}
+	}
+	return settingsLine + 1
+}
+
+// Find the line range [start, end) of the index'th item of the top level Components list, or -1
+func ymlTool_FindComponentLines(lines []string, index int) (int, int) {
+	return ymlTool_FindListItemLines(lines, "Components:", index)
+}
+
+// Find the 0 based line of a top level key such as "Rules:", or -1
+func ymlTool_FindKeyLine(lines []string, key string) int {
+	for line, text := range lines {
+		if strings.HasPrefix(text, key) {
+			return line
+		}
+	}
+	return -1
+}
+
+// Find the 1 based line of the first line containing some text, ignoring indentation (0 if not found)
+func ymlTool_FindLine(lines []string, text string) int {
+	for line, lineText := range lines {
+		if strings.HasPrefix(strings.TrimLeft(lineText, " "), text) {
+			return line + 1
+		}
+	}
+	return 0
+}
+
+// Find the line range [start, end) of the index'th item of a top level list key such as "Rules:", or -1
+func ymlTool_FindListItemLines(lines []string, key string, index int) (int, int) {
+	keyLine := ymlTool_FindKeyLine(lines, key)
+	if keyLine < 0 {
+		return -1, -1
+	}
+
+	// list items are the lines starting with "- " at the indent of the first item
+	itemIndent := -1
+	items := []int{}
+	end := len(lines)
+	for line := keyLine + 1; line < len(lines); line++ {
+		text := lines[line]
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(text) - len(trimmed)
+		if itemIndent < 0 && strings.HasPrefix(trimmed, "-") {
+			itemIndent = indent
+		}
+		if indent == 0 && !strings.HasPrefix(trimmed, "-") {
+			end = line // the next top level key
+			break
+		}
+		if indent == itemIndent && strings.HasPrefix(trimmed, "-") {
+			items = append(items, line)
+		}
+	}
+
+	if index >= len(items) {
+		return -1, -1
+	}
+	if index+1 < len(items) {
+		return items[index], items[index+1]
+	}
+	return items[index], end
+}
@@ -19,6 +19,20 @@ import (
 type ProjectComponentsConfigWrapperYaml struct {
 	configWrapper api_config.ConfigWrapper
 	components    api_builder.ProjectComponents
+	unnamed       map[string]bool // components without a Name, which were given generated keys
+	orderErr      error           // set if the components could not be put into dependency order
+
+	own       []string                        // the keys of the default scope's own components, in file order, which are what saves write
+	ownLoaded map[string]Yml_ProjectComponent // the default scope's own yml for each of its components, including Disabled ones
+	changed   map[string]bool                 // components changed using Set, which are saved into the default scope
+
+	loaded     map[string]Yml_ProjectComponent // the yml for each loaded component, which keeps fields the api components don't have
+	order      []string                        // all merged component keys in file order, including inactive components
+	conditions ConditionContext                // used to evaluate component When conditions
+
+	schemas  map[string]ProjectSettingsSchema       // component settings schemas, by builder type
+	requires map[string]ProjectRequirementsProvider // builder declared implementation requirements, by builder type
+	issues   []SettingLintIssue                     // component settings issues found at load
 }
 
 // Constructor for ProjectComponentsConfigWrapperYaml
@@ -26,6 +40,12 @@ func New_ProjectComponentsConfigWrapperYaml(configWrapper api_config.ConfigWrapp
 	return &ProjectComponentsConfigWrapperYaml{
 		configWrapper: configWrapper,
 		components:    api_builder.ProjectComponents{},
+		unnamed:       map[string]bool{},
+		ownLoaded:     map[string]Yml_ProjectComponent{},
+		changed:       map[string]bool{},
+		loaded:        map[string]Yml_ProjectComponent{},
+		schemas:       map[string]ProjectSettingsSchema{},
+		requires:      map[string]ProjectRequirementsProvider{},
 	}
 }
 
@@ -42,6 +62,28 @@ func (projectComponents *ProjectComponentsConfigWrapperYaml) DefaultScope() stri
 	return "project"
 }
 
+// Set the context used to evaluate component When conditions
+//
+// Loaded components are discarded, so that they are loaded and their
+// conditions evaluated again when next used.
+func (projectComponents *ProjectComponentsConfigWrapperYaml) SetConditionContext(conditions ConditionContext) {
+	projectComponents.conditions = conditions
+	projectComponents.components = api_builder.ProjectComponents{}
+}
+
+// Get the context used to evaluate component When conditions
+//
+// Without a context, settings are read from the same config wrapper,
+// but there is no profile or project root, so handlers which build
+// this wrapper should set a context, as the local project handler does.
+func (projectComponents *ProjectComponentsConfigWrapperYaml) ConditionContext() ConditionContext {
+	if projectComponents.conditions == nil {
+		settings := New_BaseSettingConfigWrapperYmlOperation(projectComponents.configWrapper)
+		return New_StandardConditionContext("", "", settings).ConditionContext()
+	}
+	return projectComponents.conditions
+}
+
 func (projectComponents *ProjectComponentsConfigWrapperYaml) safe() {
 	if &projectComponents.components == nil {
 		projectComponents.components = api_builder.ProjectComponents{}
@@ -60,6 +102,10 @@ func (projectComponents *ProjectComponentsConfigWrapperYaml) Get(key string) (ap
 func (projectComponents *ProjectComponentsConfigWrapperYaml) Set(key string, values api_builder.ProjectComponent) bool {
 	projectComponents.safe()
 	projectComponents.components.Set(key, values)
+	if _, found := projectComponents.loaded[key]; !found && !projectComponentsContains(projectComponents.order, key) {
+		projectComponents.order = append(projectComponents.order, key)
+	}
+	projectComponents.changed[key] = true // changes are saved as overrides in the default scope
 	if err := projectComponents.Save(); err != nil {
 		log.WithError(err).Error("Could not save build configuration")
 		return false
@@ -68,43 +114,265 @@ func (projectComponents *ProjectComponentsConfigWrapperYaml) Set(key string, val
 }
 func (projectComponents *ProjectComponentsConfigWrapperYaml) List() []string {
 	projectComponents.safe()
+	if projectComponents.orderErr != nil {
+		// activating components out of order would build broken handlers, so activate none
+		log.WithError(projectComponents.orderErr).Error("Project components could not be ordered, none will be activated")
+		return []string{}
+	}
 	return projectComponents.components.Order()
 }
 
 // Retrieve values by parsing bytes from the wrapper
+//
+// Components from all scopes are merged, starting with the lowest
+// priority scope.  A component with a Name replaces any component of
+// the same Name from a lower priority scope, keeping its position,
+// and a named component marked Disabled removes the inherited one.
+// Components without a Name are always added, with a generated key.
+//
+// The merged components are then sorted so that each comes after the
+// components which provide what it Requires, or what its builder
+// declares that its implementations require.  The Settings of each
+// component are validated against any schema registered for its Type.
+// Components whose When condition does not hold are left out, after
+// merging, so an override with a failing condition leaves out the
+// component it overrides.
 func (projectComponents *ProjectComponentsConfigWrapperYaml) Load() error {
 	projectComponents.components = api_builder.ProjectComponents{} // reset stored settings so that we can repopulate it.
+	projectComponents.unnamed = map[string]bool{}
+	projectComponents.own = []string{}
+	projectComponents.ownLoaded = map[string]Yml_ProjectComponent{}
+	projectComponents.changed = map[string]bool{}
+	projectComponents.orderErr = nil
+	projectComponents.loaded = map[string]Yml_ProjectComponent{}
+	projectComponents.issues = []SettingLintIssue{}
+	projectComponents.order = []string{}
 
 	if sources, err := projectComponents.configWrapper.Get(CONFIG_KEY_BUILDER); err == nil {
-		for _, scope := range sources.Order() {
+		order := []string{}
+		merged := map[string]Yml_ProjectComponent{}
+
+		scopes := sources.Order()
+		for scopeIndex := len(scopes) - 1; scopeIndex >= 0; scopeIndex-- {
+			scope := scopes[scopeIndex]
 			scopedSource, _ := sources.Get(scope)
 
 			scopedValues := Yml_ProjectDefintion{} // temporarily hold all settings for a specific scope in this
-			if err := yaml.Unmarshal(scopedSource, &scopedValues); err == nil {
-				for index, values := range scopedValues.Components {
-					key := scope + "_" + strconv.Itoa(index) // make a unqique key for this setting
-					log.WithFields(log.Fields{"ymlSettings": values, "key": key}).Debug("Each yml")
-					projectComponents.components.Set(key, *values.MakeProjectComponent())
+			if err := yaml.Unmarshal(scopedSource, &scopedValues); err != nil {
+				log.WithError(err).WithFields(log.Fields{"scope": scope}).Error("Couldn't marshall yml scope")
+				continue
+			}
+			log.WithFields(log.Fields{"bytes": string(scopedSource), "values": scopedValues}).Debug("Project:Config->Load()")
+			lines := ymlTool_SplitLines(scopedSource)
+
+			for index, values := range scopedValues.Components {
+				key := values.Name
+				if key == "" {
+					key = scope + "_" + strconv.Itoa(index) // make a unqique key for this setting
+					projectComponents.unnamed[key] = true
+				}
+
+				if scope == projectComponents.DefaultScope() {
+					projectComponents.own = append(projectComponents.own, key)
+					projectComponents.ownLoaded[key] = values
+				}
+
+				_, exists := merged[key]
+				if values.Disabled {
+					if key != values.Name {
+						log.WithFields(log.Fields{"scope": scope, "index": index}).Warn("Project component is disabled but has no Name, so it disables nothing")
+						continue
+					}
+					if exists {
+						delete(merged, key)
+						for orderIndex, orderKey := range order {
+							if orderKey == key {
+								order = append(order[:orderIndex], order[orderIndex+1:]...)
+								break
+							}
+						}
+					}
+					continue
+				}
+
+				log.WithFields(log.Fields{"ymlSettings": values, "key": key, "scope": scope, "override": exists}).Debug("Each yml")
+				if schema, found := projectComponents.schemas[values.Type]; found {
+					projectComponents.issues = append(projectComponents.issues, projectComponentSettingsIssues(scope, lines, index, key, values.SettingsProvider.Raw, schema)...)
 				}
-				break
+				if !exists {
+					order = append(order, key)
+				}
+				merged[key] = values
+			}
+		}
+
+		projectComponentLogIssues(projectComponents.issues)
+		projectComponents.order = order
+
+		conditions := projectComponents.ConditionContext()
+		active := []string{}
+		for _, key := range order {
+			values := merged[key]
+			projectComponents.loaded[key] = values
+			if values.When != nil && projectComponents.conditions == nil {
+				log.WithFields(log.Fields{"key": key}).Warn("Project component has a When condition, but no condition context was set, so there is no profile or project root to evaluate it with")
+			}
+			if values.When.Holds(conditions) {
+				active = append(active, key)
 			} else {
-				log.WithError(err).WithFields(log.Fields{"scope": scope}).Error("Couldn't marshall yml scope")
+				log.WithFields(log.Fields{"key": key, "when": values.When}).Debug("Project component condition does not hold, it will not be activated")
 			}
-			log.WithFields(log.Fields{"bytes": string(scopedSource), "values": scopedValues, "settings": projectComponents}).Debug("Project:Config->Load()")
 		}
-		return nil
+
+		dependencies := []ProjectDependency{}
+		for _, key := range active {
+			dependencies = append(dependencies, projectComponents.dependency(key, merged[key]))
+		}
+		sorted, err := SortProjectDependencies(dependencies, []string{})
+		if err != nil {
+			// keep the file order, so that the components can still be inspected and saved
+			projectComponents.orderErr = err
+			sorted = active
+		}
+
+		for _, key := range sorted {
+			values := merged[key]
+			projectComponents.components.Set(key, *values.MakeProjectComponent())
+		}
+		return err
 	} else {
 		log.WithError(err).Error("Error loading config for " + CONFIG_KEY_SETTINGS)
 		return err
 	}
 }
 
+// Register what the implementations of a builder type require, if the
+// builder declares it, returning whether it did
+//
+// Loaded components are discarded, so that they are ordered again when
+// next used.
+func (projectComponents *ProjectComponentsConfigWrapperYaml) RegisterBuilderRequires(builderType string, builder interface{}) bool {
+	provider, ok := builder.(ProjectRequirementsProvider)
+	if !ok {
+		return false
+	}
+	if projectComponents.requires == nil {
+		projectComponents.requires = map[string]ProjectRequirementsProvider{}
+	}
+	projectComponents.requires[builderType] = provider
+	projectComponents.components = api_builder.ProjectComponents{}
+	return true
+}
+
+// Describe the dependencies of a component, adding what its builder declares
+// that its implementations require (unless the component provides it itself)
+func (projectComponents *ProjectComponentsConfigWrapperYaml) dependency(key string, component Yml_ProjectComponent) ProjectDependency {
+	dependency := component.Dependency(key)
+	provider, found := projectComponents.requires[component.Type]
+	if !found {
+		return dependency
+	}
+
+	requires := append([]string{}, dependency.Requires...)
+	for _, implementation := range component.Implementations {
+		for _, required := range provider.ImplementationRequires(implementation) {
+			if !projectDependencyProvides(dependency, required) && !projectComponentsContains(requires, required) {
+				requires = append(requires, required)
+			}
+		}
+	}
+	dependency.Requires = requires
+	return dependency
+}
+
 // Save the current values to the wrapper
+//
+// The default scope's own components are written back as they were
+// loaded, in file order, including those which are disabled, or are
+// overridden or disabled by a higher priority scope.  Components
+// changed using Set are written into the default scope, replacing its
+// own definition, or appended if it had none.  Any other top level
+// keys in that scope are kept, although yml comments are not.
 func (projectComponents *ProjectComponentsConfigWrapperYaml) Save() error {
-	/**
-	 * @TODO THIS
-	 */
-	return errors.New("ProjectComponentsConfigWrapperYaml Set operation not yet written.")
+	defaultScope := projectComponents.DefaultScope()
+
+	keys := append([]string{}, projectComponents.own...)
+	for _, key := range append(append([]string{}, projectComponents.order...), projectComponents.components.Order()...) {
+		if projectComponents.changed[key] && !projectComponentsContains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	written := map[string]Yml_ProjectComponent{}
+	ymlComponents := []Yml_ProjectComponent{}
+	for _, key := range keys {
+		ymlComponent, found := projectComponents.ownLoaded[key]
+		if projectComponents.changed[key] {
+			if component, err := projectComponents.components.Get(key); err == nil {
+				loaded, wasOwn := projectComponents.ownLoaded[key]
+				if !wasOwn || loaded.Disabled {
+					loaded = projectComponents.loaded[key]
+				}
+				ymlComponent = New_Yml_ProjectComponent(component)
+				ymlComponent.Requires = loaded.Requires
+				ymlComponent.Provides = loaded.Provides
+				ymlComponent.When = loaded.When
+				if !projectComponents.unnamed[key] {
+					ymlComponent.Name = key
+				}
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+		written[key] = ymlComponent
+		ymlComponents = append(ymlComponents, ymlComponent)
+	}
+
+	// start from the existing default scope source, so that other top level keys are kept
+	document := yaml.MapSlice{}
+	if sources, err := projectComponents.configWrapper.Get(CONFIG_KEY_BUILDER); err == nil {
+		if scopedSource, found := sources.Get(defaultScope); found {
+			if err := yaml.Unmarshal(scopedSource, &document); err != nil {
+				return errors.New("Could not parse existing project config in scope " + defaultScope + ": " + err.Error())
+			}
+		}
+	}
+
+	replaced := false
+	for index, item := range document {
+		if item.Key == "Components" {
+			document[index].Value = ymlComponents
+			replaced = true
+		}
+	}
+	if !replaced {
+		document = append(document, yaml.MapItem{Key: "Components", Value: ymlComponents})
+	}
+
+	scopedSource, err := yaml.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	scopedValues := api_config.ConfigScopedValues{}
+	scopedValues.Set(defaultScope, api_config.ConfigScopedValue(scopedSource))
+	if err := projectComponents.configWrapper.Set(CONFIG_KEY_BUILDER, scopedValues); err != nil {
+		return err
+	}
+
+	// the changed components are now the default scope's own
+	for _, key := range keys {
+		if ymlComponent, found := written[key]; found {
+			if !projectComponentsContains(projectComponents.own, key) {
+				projectComponents.own = append(projectComponents.own, key)
+			}
+			projectComponents.ownLoaded[key] = ymlComponent
+		}
+	}
+	projectComponents.changed = map[string]bool{}
+	return nil
 }
 
 // A temporary holder for the list of components from the yml components file
@@ -114,9 +382,49 @@ type Yml_ProjectDefintion struct {
 
 // A temporary holder of ProjectComponents, just for yml parsing (probably not needed even)
 type Yml_ProjectComponent struct {
-	Type             string                             `yaml:"Type"`
-	Implementations  []string                           `yaml:"Implementations"`
-	SettingsProvider Yml_ProjectSettingSettingsProvider `yaml:"Settings"`
+	Name             string                             `yaml:"Name,omitempty"`
+	Disabled         bool                               `yaml:"Disabled,omitempty"`
+	Type             string                             `yaml:"Type,omitempty"`
+	Implementations  []string                           `yaml:"Implementations,omitempty"`
+	Requires         []string                           `yaml:"Requires,omitempty"`
+	Provides         []string                           `yaml:"Provides,omitempty"` // defaults to the Implementations
+	When             *Yml_Condition                     `yaml:"When,omitempty"`     // the component is only activated if this holds
+	SettingsProvider Yml_ProjectSettingSettingsProvider `yaml:"Settings,omitempty"`
+}
+
+// Convert a ProjectComponent into the YML struct, so that it can be saved
+func New_Yml_ProjectComponent(component api_builder.ProjectComponent) Yml_ProjectComponent {
+	ymlComponent := Yml_ProjectComponent{
+		Type:            component.Type(),
+		Implementations: component.Implementations().Order(),
+	}
+
+	switch provider := component.SettingsProvider().(type) {
+	case Yml_ProjectSettingSettingsProvider:
+		ymlComponent.SettingsProvider = provider
+	case *Yml_ProjectSettingSettingsProvider:
+		ymlComponent.SettingsProvider = *provider
+	case nil:
+	default:
+		// other providers can only be saved if they can assign their settings as generic yml
+		var raw interface{}
+		if err := provider.AssignSettings(&raw); err == nil {
+			ymlComponent.SettingsProvider.Raw = raw
+		} else {
+			log.WithError(err).WithFields(log.Fields{"type": component.Type()}).Error("Could not convert component settings for saving, they will be left out")
+		}
+	}
+
+	return ymlComponent
+}
+
+// Describe the dependencies of this component, for ordering
+func (component Yml_ProjectComponent) Dependency(key string) ProjectDependency {
+	provides := component.Provides
+	if len(provides) == 0 {
+		provides = component.Implementations
+	}
+	return ProjectDependency{Id: key, Requires: component.Requires, Provides: provides}
 }
 
 // Convert this YML struct into a proper ProjectSetting struct
@@ -127,18 +435,58 @@ func (component *Yml_ProjectComponent) MakeProjectComponent() *api_builder.Proje
 // Yml builder SettingProvider
 type Yml_ProjectSettingSettingsProvider struct {
 	UnMarshaler func(interface{}) error
+	Raw         interface{} // the raw settings block, kept so that it can be saved as it was
 }
 
 // Yaml custom UnMarshall handler
 func (ymlSettingsProvider *Yml_ProjectSettingSettingsProvider) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	ymlSettingsProvider.UnMarshaler = unmarshal
+
+	// keep map key order where the settings are a map
+	var ordered yaml.MapSlice
+	if err := unmarshal(&ordered); err == nil {
+		ymlSettingsProvider.Raw = ordered
+	} else {
+		var raw interface{}
+		if err := unmarshal(&raw); err == nil {
+			ymlSettingsProvider.Raw = raw
+		}
+	}
 	return nil
 }
 
+// Yaml custom Marshall handler, which writes the raw settings block
+func (ymlSettingsProvider Yml_ProjectSettingSettingsProvider) MarshalYAML() (interface{}, error) {
+	return ymlSettingsProvider.Raw, nil
+}
+
+// Are there no settings, used to leave the settings out when saving
+func (ymlSettingsProvider Yml_ProjectSettingSettingsProvider) IsZero() bool {
+	return ymlSettingsProvider.Raw == nil
+}
+
 // UnMarshaller function
 func (ymlSettingsProvider Yml_ProjectSettingSettingsProvider) AssignSettings(target interface{}) error {
 	if ymlSettingsProvider.UnMarshaler != nil {
 		return ymlSettingsProvider.UnMarshaler(target)
 	}
+	if ymlSettingsProvider.Raw != nil {
+		// providers made in code have no unmarshaler, so round trip the raw settings
+		source, err := yaml.Marshal(ymlSettingsProvider.Raw)
+		if err != nil {
+			return err
+		}
+		return yaml.Unmarshal(source, target)
+	}
 	return nil
 }
+
+// Does a list of component keys contain a key
+func projectComponentsContains(keys []string, key string) bool {
+	for _, existing := range keys {
+		if existing == key {
+			return true
+		}
+	}
+	return false
+}
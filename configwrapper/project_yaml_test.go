@@ -0,0 +1,185 @@
+package configwrapper
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	api_builder "github.com/wunderkraut/radi-api/builder"
+	api_config "github.com/wunderkraut/radi-api/operation/config"
+)
+
+// A config wrapper which keeps scoped sources in memory, highest priority scope first
+type projectTestConfigWrapper struct {
+	values map[string]api_config.ConfigScopedValues
+}
+
+// Get the scoped sources for a key
+func (wrapper *projectTestConfigWrapper) Get(key string) (api_config.ConfigScopedValues, error) {
+	return wrapper.values[key], nil
+}
+
+// Set scoped sources for a key, replacing only the scopes given
+func (wrapper *projectTestConfigWrapper) Set(key string, values api_config.ConfigScopedValues) error {
+	stored := wrapper.values[key]
+	for _, scope := range values.Order() {
+		source, _ := values.Get(scope)
+		stored.Set(scope, source)
+	}
+	wrapper.values[key] = stored
+	return nil
+}
+
+// List the keys below a parent
+func (wrapper *projectTestConfigWrapper) List(parent string) ([]string, error) {
+	keys := []string{}
+	for key := range wrapper.values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Reformat a yml source, so that sources can be compared without their formatting
+func projectTestNormalizeYml(t *testing.T, source []byte) string {
+	document := yaml.MapSlice{}
+	if err := yaml.Unmarshal(source, &document); err != nil {
+		t.Fatal(err)
+	}
+	normalized, err := yaml.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(normalized)
+}
+
+func TestProjectComponentsSave(t *testing.T) {
+	user := `
+Components:
+  - {Name: files, Type: local, Implementations: [config]}
+  - {Name: remote, Type: upcloud, Implementations: [orchestrate], Requires: [config]}
+`
+	project := `
+Version: 1
+Components:
+  - {Name: files, Type: local, Implementations: [config, setting]}
+  - {Name: old, Disabled: true}
+  - {Type: "null", Implementations: [command]}
+`
+
+	tests := []struct {
+		name       string
+		scopes     []securityCombineTestScope // highest priority first
+		key        string                     // a component to Set, or empty to only Save
+		component  *api_builder.ProjectComponent
+		saved      string
+		components []string // the component keys after loading the saved config again
+	}{
+		{
+			name:   "unchanged save keeps own components",
+			scopes: []securityCombineTestScope{{"user", user}, {"project", project}},
+			saved: `
+Version: 1
+Components:
+  - {Name: files, Type: local, Implementations: [config, setting]}
+  - {Name: old, Disabled: true}
+  - {Type: "null", Implementations: [command]}
+`,
+			components: []string{"files", "project_2", "remote"},
+		},
+		{
+			name:      "changed component replaces its own definition",
+			scopes:    []securityCombineTestScope{{"project", project}},
+			key:       "files",
+			component: api_builder.New_ProjectComponent("local", *api_builder.New_Implementations([]string{"config", "setting", "project"}), nil),
+			saved: `
+Version: 1
+Components:
+  - {Name: files, Type: local, Implementations: [config, setting, project]}
+  - {Name: old, Disabled: true}
+  - {Type: "null", Implementations: [command]}
+`,
+			components: []string{"files", "project_2"},
+		},
+		{
+			name:      "new component is appended",
+			scopes:    []securityCombineTestScope{{"project", project}},
+			key:       "docker",
+			component: api_builder.New_ProjectComponent("docker", *api_builder.New_Implementations([]string{"orchestrate"}), nil),
+			saved: `
+Version: 1
+Components:
+  - {Name: files, Type: local, Implementations: [config, setting]}
+  - {Name: old, Disabled: true}
+  - {Type: "null", Implementations: [command]}
+  - {Name: docker, Type: docker, Implementations: [orchestrate]}
+`,
+			components: []string{"files", "project_2", "docker"},
+		},
+		{
+			name:      "changed inherited component keeps its requirements",
+			scopes:    []securityCombineTestScope{{"user", user}, {"project", project}},
+			key:       "remote",
+			component: api_builder.New_ProjectComponent("upcloud", *api_builder.New_Implementations([]string{"orchestrate", "monitor"}), nil),
+			saved: `
+Version: 1
+Components:
+  - {Name: files, Type: local, Implementations: [config, setting]}
+  - {Name: old, Disabled: true}
+  - {Type: "null", Implementations: [command]}
+  - {Name: remote, Type: upcloud, Implementations: [orchestrate, monitor], Requires: [config]}
+`,
+			components: []string{"files", "project_2", "remote"},
+		},
+		{
+			name:      "missing default scope is created",
+			scopes:    []securityCombineTestScope{{"user", user}},
+			key:       "files",
+			component: api_builder.New_ProjectComponent("local", *api_builder.New_Implementations([]string{"config"}), nil),
+			saved: `
+Components:
+  - {Name: files, Type: local, Implementations: [config]}
+`,
+			components: []string{"files", "remote"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sources := api_config.ConfigScopedValues{}
+			for _, scope := range test.scopes {
+				sources.Set(scope.scope, api_config.ConfigScopedValue(scope.source))
+			}
+			wrapper := &projectTestConfigWrapper{values: map[string]api_config.ConfigScopedValues{CONFIG_KEY_BUILDER: sources}}
+
+			projectComponents := New_ProjectComponentsConfigWrapperYaml(wrapper)
+			if err := projectComponents.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if test.component != nil {
+				if !projectComponents.Set(test.key, *test.component) {
+					t.Fatal("could not set component " + test.key)
+				}
+			} else if err := projectComponents.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			saved := wrapper.values[CONFIG_KEY_BUILDER]
+			source, found := saved.Get(projectComponents.DefaultScope())
+			if !found {
+				t.Fatal("nothing was saved to the default scope")
+			}
+			if expected, got := projectTestNormalizeYml(t, []byte(test.saved)), projectTestNormalizeYml(t, source); got != expected {
+				t.Errorf("expected saved source:\n%s\ngot:\n%s", expected, got)
+			}
+
+			reloaded := New_ProjectComponentsConfigWrapperYaml(wrapper)
+			if err := reloaded.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if components := reloaded.List(); !reflect.DeepEqual(components, test.components) {
+				t.Errorf("expected components %v after loading again, got %v", test.components, components)
+			}
+		})
+	}
+}
@@ -18,11 +18,16 @@ const (
 	// The Config key for settings
 	CONFIG_KEY_SECURITY_AUTHORIZE = "authorize"
 	CONFIG_KEY_SECURITY_USER      = "user"
+	CONFIG_KEY_SECURITY_ROLES     = "roles"
+
+	// The scope trusted to decide how authorization rules combine
+	SECURITY_PROJECT_SCOPE = "project"
 )
 
 // SecurityWrapper definition
 type SecurityConfigWrapper interface {
 	AuthorizeOperation(api_operation.Operation) api_security.RuleResult
+	ExplainOperation(api_operation.Operation) (api_security.RuleResult, SecurityAuthorizeTrace)
 	CurrentUser() api_security.SecurityUser
 	// AuthenticateUser()
 }
@@ -104,6 +109,8 @@ type SecurityConfigWrapperAuthorizeOperation struct {
 	api_security.BaseSecurityAuthorizeOperation
 
 	targetOperation api_operation.Operation
+
+	Audit SecurityAuditLog // optional log of every decision, @see security_audit.go
 }
 
 // Run a validation check on the Operation
@@ -124,6 +131,7 @@ func (authorize *SecurityConfigWrapperAuthorizeOperation) Properties() api_prope
 	props.Add(api_property.Property(&api_security.SecurityAuthorizationOperationProperty{}))
 	props.Add(api_property.Property(&api_security.SecurityAuthorizationRuleResultProperty{}))
 	props.Add(api_property.Property(&api_security.SecurityAuthorizationSucceededProperty{}))
+	props.Add(api_property.Property(&SecurityAuthorizeTraceProperty{}))
 
 	return props.Properties()
 }
@@ -134,8 +142,9 @@ func (authorize *SecurityConfigWrapperAuthorizeOperation) Exec(props api_propert
 
 	securityWrapper := authorize.SecurityConfigWrapper()
 
+	// the acting user, such as an authenticated session user, rather than only the user config
 	userProp, _ := props.Get(api_security.SECURITY_USER_PROPERTY_KEY)
-	userProp.Set(securityWrapper.CurrentUser())
+	userProp.Set(securityActingUser(securityWrapper))
 
 	/**
 	 * Authorize this operation, as opposed to the child, because this operation
@@ -144,12 +153,24 @@ func (authorize *SecurityConfigWrapperAuthorizeOperation) Exec(props api_propert
 	 * operation in most ways.
 	 */
 
-	ruleResult := securityWrapper.AuthorizeOperation(authorize)
+	ruleResult, trace := securityWrapper.ExplainOperation(authorize)
 
 	propRuleResult, _ := props.Get(api_security.SECURITY_AUTHORIZATION_RULERESULT_PROPERTY_KEY)
 	propRuleResult.Set(ruleResult)
 	propSuccess, _ := props.Get(api_security.SECURITY_AUTHORIZATION_SUCCEEDED_PROPERTY_KEY)
 	propSuccess.Set(ruleResult.Allow())
+	if propTrace, found := props.Get(OPERATION_PROPERTY_SECURITY_AUTHORIZE_TRACE); found {
+		propTrace.Set(trace)
+	}
+
+	// audit the property values of the decorated operation, if it is known
+	var audited api_operation.Operation = authorize
+	if propOperation, found := props.Get((&api_security.SecurityAuthorizationOperationProperty{}).Id()); found {
+		if target, ok := propOperation.Get().(api_operation.Operation); ok && target != nil {
+			audited = target
+		}
+	}
+	securityAuditDecision(authorize.Audit, audited, trace)
 
 	res.MarkSuccess()
 	res.MarkFinished()
@@ -0,0 +1,476 @@
+package configwrapper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	api_operation "github.com/wunderkraut/radi-api/operation"
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_result "github.com/wunderkraut/radi-api/result"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+
+	api_security "github.com/wunderkraut/radi-api/operation/security"
+	api_setting "github.com/wunderkraut/radi-api/operation/setting"
+)
+
+/**
+ * Authorization audit, which records each authorization
+ * decision with the operation property values that it was
+ * made on, with secret values redacted.
+ */
+
+const (
+	// Operation id for the audit query operation
+	OPERATION_ID_SECURITY_AUDIT = "security.audit"
+
+	// Properties for the audit operation
+	OPERATION_PROPERTY_SECURITY_AUDIT_USER      = "security.audit.user"
+	OPERATION_PROPERTY_SECURITY_AUDIT_OPERATION = "security.audit.operation"
+	OPERATION_PROPERTY_SECURITY_AUDIT_SINCE     = "security.audit.since"
+	OPERATION_PROPERTY_SECURITY_AUDIT_UNTIL     = "security.audit.until"
+	OPERATION_PROPERTY_SECURITY_AUDIT_RECORDS   = "security.audit.records"
+
+	// The value recorded in place of secret property values
+	SECURITY_AUDIT_REDACTED = "[redacted]"
+)
+
+// Parts of property ids which mark their values as secret
+var SecurityAuditSecretWords = []string{"password", "secret", "token", "credential", "private"}
+
+// A log of authorization decisions, which records can be appended to and read from
+type SecurityAuditLog interface {
+	Append(record SecurityAuditRecord) error
+	Records() ([]SecurityAuditRecord, error)
+}
+
+// A single authorization decision record
+type SecurityAuditRecord struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	User       string            `json:"user"`
+	Operation  string            `json:"operation"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Rule       string            `json:"rule,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Decision   string            `json:"decision"`
+}
+
+// Make a decision record from an authorization trace, and the operation that was authorized
+//
+// Only the properties which the rules inspected are recorded, with the
+// values from the trace, so that values redacted in the trace stay
+// redacted.  Properties with secret looking ids, and secret setting
+// values, are also redacted here.
+func New_SecurityAuditRecord(op api_operation.Operation, trace SecurityAuthorizeTrace) SecurityAuditRecord {
+	record := SecurityAuditRecord{
+		Timestamp: time.Now(),
+		User:      trace.Actor.Id,
+		Operation: trace.Operation,
+		Rule:      trace.DecidingRule,
+		Message:   trace.Message,
+		Decision:  trace.Decision,
+	}
+	if record.User == "" {
+		record.User = "anonymous"
+	}
+
+	props := op.Properties()
+	for _, ruleTrace := range trace.Rules {
+		for _, propTrace := range ruleTrace.Properties {
+			id := propTrace.Property
+			if !propTrace.Found {
+				continue
+			}
+			if _, recorded := record.Properties[id]; recorded {
+				continue
+			}
+			if prop, found := props.Get(id); found {
+				if _, isUser := prop.Get().(api_security.SecurityUser); isUser {
+					continue // the user is recorded on its own
+				}
+			}
+
+			if record.Properties == nil {
+				record.Properties = map[string]string{}
+			}
+			if securityPropertySecret(props, id, nil) {
+				record.Properties[id] = SECURITY_AUDIT_REDACTED
+			} else {
+				record.Properties[id] = propTrace.Value
+			}
+		}
+	}
+	return record
+}
+
+// Does a property id look like it holds a secret
+func securityAuditSecret(id string) bool {
+	id = strings.ToLower(id)
+	for _, word := range SecurityAuditSecretWords {
+		if strings.Contains(id, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Does an operation property hold a secret value
+//
+// A property is secret if its id looks secret, or if it is the value
+// of a setting whose key is secret, by the settings schema if one is
+// passed, or else by the secret setting patterns.
+func securityPropertySecret(props api_property.Properties, id string, schema *SettingsSchema) bool {
+	if securityAuditSecret(id) {
+		return true
+	}
+	if id != api_setting.OPERATION_PROPERTY_SETTING_VALUE {
+		return false
+	}
+	keyProp, found := props.Get(api_setting.OPERATION_PROPERTY_SETTING_KEY)
+	if !found {
+		return false
+	}
+	key, _ := keyProp.Get().(string)
+	if schema != nil {
+		return schema.Secret(key)
+	}
+	return SettingKeyIsSecret(key)
+}
+
+// Describe a property value for traces and audit records, so that a single value is kept as it is
+func securityPropertyValue(value interface{}) string {
+	values, err := authPropertyValues(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	texts := []string{}
+	for _, value := range values {
+		texts = append(texts, authValueString(value))
+	}
+	if len(texts) == 1 {
+		return texts[0]
+	}
+	return "[" + strings.Join(texts, ", ") + "]"
+}
+
+// Record an authorization decision in an audit log, which may be nil if auditing is off
+func securityAuditDecision(audit SecurityAuditLog, op api_operation.Operation, trace SecurityAuthorizeTrace) {
+	if audit == nil {
+		return
+	}
+	if err := audit.Append(New_SecurityAuditRecord(op, trace)); err != nil {
+		log.WithError(err).WithFields(log.Fields{"operation": trace.Operation}).Error("Could not append authorization decision to the audit log")
+	}
+}
+
+// Parse an audit time filter, which is either an RFC3339 time, or a duration before now such as 24h
+func securityAuditParseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	return time.Time{}, errors.New("Audit time " + value + " must be an RFC3339 time, or a duration before now such as 24h")
+}
+
+// A Security Audit operation that queries an authorization audit log
+type SecurityAuditOperation struct {
+	Audit SecurityAuditLog
+}
+
+// Id the operation
+func (audit SecurityAuditOperation) Id() string {
+	return OPERATION_ID_SECURITY_AUDIT
+}
+
+// Label the operation
+func (audit SecurityAuditOperation) Label() string {
+	return "Authorization audit"
+}
+
+// Description for the operation
+func (audit SecurityAuditOperation) Description() string {
+	return "List recorded authorization decisions, optionally filtered by user, operation and time."
+}
+
+// Help text for the operation
+func (audit SecurityAuditOperation) Help() string {
+	return "Each record holds the time, user, operation, property values (with secrets redacted), the deciding rule and message, and the decision.  Times can be given as RFC3339 times, or as durations before now such as 24h."
+}
+
+// Usage for the operation
+func (audit SecurityAuditOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+// Validate the operation
+func (audit SecurityAuditOperation) Validate() api_result.Result {
+	return api_result.MakeSuccessfulResult()
+}
+
+// Get properties
+func (audit SecurityAuditOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SecurityAuditUserProperty{}))
+	props.Add(api_property.Property(&SecurityAuditOperationProperty{}))
+	props.Add(api_property.Property(&SecurityAuditSinceProperty{}))
+	props.Add(api_property.Property(&SecurityAuditUntilProperty{}))
+	props.Add(api_property.Property(&SecurityAuditRecordsProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (audit SecurityAuditOperation) Exec(props api_property.Properties) api_result.Result {
+	res := api_result.New_StandardResult()
+
+	user, operation, since, until := "", "", "", ""
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_AUDIT_USER); found {
+		user, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_AUDIT_OPERATION); found {
+		operation, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_AUDIT_SINCE); found {
+		since, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_AUDIT_UNTIL); found {
+		until, _ = prop.Get().(string)
+	}
+	recordsProp, _ := props.Get(OPERATION_PROPERTY_SECURITY_AUDIT_RECORDS)
+
+	if matched, err := audit.query(user, operation, since, until, time.Now()); err == nil {
+		recordsProp.Set(matched)
+		res.MarkSuccess()
+	} else {
+		res.MarkFailed()
+		res.AddError(err)
+	}
+	res.MarkFinished()
+
+	return res.Result()
+}
+
+// Get the records matching filters, in time order, where empty filters match any record
+func (audit SecurityAuditOperation) query(user string, operation string, since string, until string, now time.Time) ([]SecurityAuditRecord, error) {
+	matched := []SecurityAuditRecord{}
+
+	sinceTime, err := securityAuditParseTime(since, now)
+	if err != nil {
+		return matched, err
+	}
+	untilTime, err := securityAuditParseTime(until, now)
+	if err != nil {
+		return matched, err
+	}
+	if audit.Audit == nil {
+		return matched, nil
+	}
+
+	records, err := audit.Audit.Records()
+	if err != nil {
+		return matched, err
+	}
+	for _, record := range records {
+		if (user == "" || record.User == user) &&
+			(operation == "" || record.Operation == operation) &&
+			(sinceTime.IsZero() || !record.Timestamp.Before(sinceTime)) &&
+			(untilTime.IsZero() || record.Timestamp.Before(untilTime)) {
+			matched = append(matched, record)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.Before(matched[j].Timestamp) })
+	return matched, nil
+}
+
+/**
+ * Properties
+ */
+
+// Property for filtering audit records by user
+type SecurityAuditUserProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (user *SecurityAuditUserProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_AUDIT_USER
+}
+
+// Label for the Property
+func (user *SecurityAuditUserProperty) Label() string {
+	return "User"
+}
+
+// Description for the Property
+func (user *SecurityAuditUserProperty) Description() string {
+	return "Only list decisions for this user id."
+}
+
+// Is the Property internal only
+func (user *SecurityAuditUserProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (user *SecurityAuditUserProperty) Copy() api_property.Property {
+	prop := &SecurityAuditUserProperty{}
+	prop.Set(user.Get())
+	return api_property.Property(prop)
+}
+
+// Property for filtering audit records by operation
+type SecurityAuditOperationProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (operation *SecurityAuditOperationProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_AUDIT_OPERATION
+}
+
+// Label for the Property
+func (operation *SecurityAuditOperationProperty) Label() string {
+	return "Operation"
+}
+
+// Description for the Property
+func (operation *SecurityAuditOperationProperty) Description() string {
+	return "Only list decisions for this operation id."
+}
+
+// Is the Property internal only
+func (operation *SecurityAuditOperationProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (operation *SecurityAuditOperationProperty) Copy() api_property.Property {
+	prop := &SecurityAuditOperationProperty{}
+	prop.Set(operation.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the start of an audit time range
+type SecurityAuditSinceProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (since *SecurityAuditSinceProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_AUDIT_SINCE
+}
+
+// Label for the Property
+func (since *SecurityAuditSinceProperty) Label() string {
+	return "Since"
+}
+
+// Description for the Property
+func (since *SecurityAuditSinceProperty) Description() string {
+	return "Only list decisions made at or after this time."
+}
+
+// Is the Property internal only
+func (since *SecurityAuditSinceProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (since *SecurityAuditSinceProperty) Copy() api_property.Property {
+	prop := &SecurityAuditSinceProperty{}
+	prop.Set(since.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the end of an audit time range
+type SecurityAuditUntilProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (until *SecurityAuditUntilProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_AUDIT_UNTIL
+}
+
+// Label for the Property
+func (until *SecurityAuditUntilProperty) Label() string {
+	return "Until"
+}
+
+// Description for the Property
+func (until *SecurityAuditUntilProperty) Description() string {
+	return "Only list decisions made before this time."
+}
+
+// Is the Property internal only
+func (until *SecurityAuditUntilProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (until *SecurityAuditUntilProperty) Copy() api_property.Property {
+	prop := &SecurityAuditUntilProperty{}
+	prop.Set(until.Get())
+	return api_property.Property(prop)
+}
+
+// Property holding matched authorization decision records
+type SecurityAuditRecordsProperty struct {
+	value []SecurityAuditRecord
+}
+
+// Id for the Property
+func (records *SecurityAuditRecordsProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_AUDIT_RECORDS
+}
+
+// Give an idea of what type of value the property consumes
+func (records *SecurityAuditRecordsProperty) Type() string {
+	return "handler/configwrapper.[]SecurityAuditRecord"
+}
+
+// Label for the Property
+func (records *SecurityAuditRecordsProperty) Label() string {
+	return "Authorization decisions"
+}
+
+// Description for the Property
+func (records *SecurityAuditRecordsProperty) Description() string {
+	return "Recorded authorization decisions which matched the audit filters."
+}
+
+// Is the Property internal only
+func (records *SecurityAuditRecordsProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Property Accessors
+func (records *SecurityAuditRecordsProperty) Get() interface{} {
+	return interface{}(records.value)
+}
+func (records *SecurityAuditRecordsProperty) Set(value interface{}) bool {
+	if converted, ok := value.([]SecurityAuditRecord); ok {
+		records.value = converted
+		return true
+	} else {
+		log.WithFields(log.Fields{"value": value}).Error("Could not assign Property value, because the passed parameter was the wrong type. Expected []configwrapper.SecurityAuditRecord")
+		return false
+	}
+}
+
+// Copy the property
+func (records *SecurityAuditRecordsProperty) Copy() api_property.Property {
+	prop := &SecurityAuditRecordsProperty{}
+	prop.Set(records.Get())
+	return api_property.Property(prop)
+}
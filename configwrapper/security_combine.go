@@ -0,0 +1,63 @@
+package configwrapper
+
+import (
+	"strings"
+)
+
+/**
+ * Rule combining algorithms, which decide how the results
+ * of several matching authorization rules are combined into
+ * a single decision.  The algorithm is chosen by the Combine
+ * key of the project authorize.yml Settings.
+ *
+ * Only the project scope, and its profile overlays, can choose
+ * the algorithm, and its rules are evaluated before the rules
+ * of other scopes, so that a user scope cannot outrank them.
+ */
+
+const (
+	// The first rule which allows or denies decides
+	SECURITY_COMBINE_FIRST_APPLICABLE = "first-applicable"
+	// Any rule which denies decides, otherwise any rule which allows
+	SECURITY_COMBINE_DENY_OVERRIDES = "deny-overrides"
+	// Any rule which allows decides, otherwise any rule which denies
+	SECURITY_COMBINE_PERMIT_OVERRIDES = "permit-overrides"
+	// Rules are ordered by Priority (highest first), then the first rule which allows or denies decides
+	SECURITY_COMBINE_PRIORITY = "priority"
+)
+
+// Is a scope the project scope or one of its profile overlays, such as project.stage
+func securityProjectScope(scope string) bool {
+	return scope == SECURITY_PROJECT_SCOPE || strings.HasPrefix(scope, SECURITY_PROJECT_SCOPE+".")
+}
+
+// Is a combining algorithm known
+func securityCombineValid(combine string) bool {
+	switch combine {
+	case SECURITY_COMBINE_FIRST_APPLICABLE, SECURITY_COMBINE_DENY_OVERRIDES, SECURITY_COMBINE_PERMIT_OVERRIDES, SECURITY_COMBINE_PRIORITY:
+		return true
+	}
+	return false
+}
+
+// Does a rule auth value replace an earlier decision
+func securityCombineOverrides(combine string, authValue int) bool {
+	switch combine {
+	case SECURITY_COMBINE_DENY_OVERRIDES:
+		return authValue < 0
+	case SECURITY_COMBINE_PERMIT_OVERRIDES:
+		return authValue > 0
+	}
+	return false
+}
+
+// Does a rule auth value end the evaluation, as no later rule can change the decision
+func securityCombineFinal(combine string, authValue int) bool {
+	switch combine {
+	case SECURITY_COMBINE_DENY_OVERRIDES:
+		return authValue < 0
+	case SECURITY_COMBINE_PERMIT_OVERRIDES:
+		return authValue > 0
+	}
+	return authValue != 0
+}
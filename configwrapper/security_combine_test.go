@@ -0,0 +1,172 @@
+package configwrapper
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A scope of authorize.yml source, for building a rule handler
+type securityCombineTestScope struct {
+	scope  string
+	source string
+}
+
+func TestSecurityCombine(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []securityCombineTestScope
+		combine  string
+		decision string
+		rule     string
+	}{
+		{
+			name: "first applicable by default",
+			scopes: []securityCombineTestScope{{"project", `
+Rules:
+  - {Id: allow-up, Operation: "^orchestrate.up$", Authorize: allow}
+  - {Id: deny-all, Operation: "*", Authorize: deny}
+`}},
+			combine:  SECURITY_COMBINE_FIRST_APPLICABLE,
+			decision: SECURITY_TRACE_DECISION_ALLOW,
+			rule:     "project:allow-up",
+		},
+		{
+			name: "deny overrides",
+			scopes: []securityCombineTestScope{{"project", `
+Settings: {Combine: deny-overrides}
+Rules:
+  - {Id: allow-up, Operation: "^orchestrate.up$", Authorize: allow}
+  - {Id: deny-all, Operation: "*", Authorize: deny}
+`}},
+			combine:  SECURITY_COMBINE_DENY_OVERRIDES,
+			decision: SECURITY_TRACE_DECISION_DENY,
+			rule:     "project:deny-all",
+		},
+		{
+			name: "permit overrides",
+			scopes: []securityCombineTestScope{{"project", `
+Settings: {Combine: permit-overrides}
+Rules:
+  - {Id: deny-all, Operation: "*", Authorize: deny}
+  - {Id: allow-up, Operation: "^orchestrate.up$", Authorize: allow}
+`}},
+			combine:  SECURITY_COMBINE_PERMIT_OVERRIDES,
+			decision: SECURITY_TRACE_DECISION_ALLOW,
+			rule:     "project:allow-up",
+		},
+		{
+			name: "priority",
+			scopes: []securityCombineTestScope{{"project", `
+Settings: {Combine: priority}
+Rules:
+  - {Id: allow-up, Operation: "^orchestrate.up$", Authorize: allow, Priority: 1}
+  - {Id: deny-all, Operation: "*", Authorize: deny, Priority: 5}
+`}},
+			combine:  SECURITY_COMBINE_PRIORITY,
+			decision: SECURITY_TRACE_DECISION_DENY,
+			rule:     "project:deny-all",
+		},
+		{
+			name: "unknown algorithm denies first",
+			scopes: []securityCombineTestScope{{"project", `
+Settings: {Combine: majority}
+Rules:
+  - {Id: allow-up, Operation: "^orchestrate.up$", Authorize: allow}
+  - {Id: deny-all, Operation: "*", Authorize: deny}
+`}},
+			combine:  SECURITY_COMBINE_DENY_OVERRIDES,
+			decision: SECURITY_TRACE_DECISION_DENY,
+			rule:     "project:deny-all",
+		},
+		{
+			name: "no rule applies",
+			scopes: []securityCombineTestScope{{"project", `
+Rules:
+  - {Id: deny-down, Operation: "^orchestrate.down$", Authorize: deny}
+`}},
+			combine:  SECURITY_COMBINE_FIRST_APPLICABLE,
+			decision: SECURITY_TRACE_DECISION_NONE,
+		},
+		{
+			name: "user scope cannot choose the algorithm",
+			scopes: []securityCombineTestScope{
+				{"user", `
+Settings: {Combine: permit-overrides}
+Rules:
+  - {Id: allow-all, Operation: "*", Authorize: allow}
+`},
+				{"project", `
+Rules:
+  - {Id: deny-up, Operation: "^orchestrate.up$", Authorize: deny}
+`},
+			},
+			combine:  SECURITY_COMBINE_FIRST_APPLICABLE,
+			decision: SECURITY_TRACE_DECISION_DENY,
+			rule:     "project:deny-up",
+		},
+		{
+			name: "user scope priority cannot outrank the project",
+			scopes: []securityCombineTestScope{
+				{"user", `
+Rules:
+  - {Id: deny-all, Operation: "*", Authorize: deny, Priority: 100}
+`},
+				{"project", `
+Settings: {Combine: priority}
+Rules:
+  - {Id: allow-up, Operation: "^orchestrate.up$", Authorize: allow, Priority: 1}
+`},
+			},
+			combine:  SECURITY_COMBINE_PRIORITY,
+			decision: SECURITY_TRACE_DECISION_ALLOW,
+			rule:     "project:allow-up",
+		},
+		{
+			name: "project profile overlay chooses the algorithm",
+			scopes: []securityCombineTestScope{
+				{"project.stage", `
+Settings: {Combine: deny-overrides}
+`},
+				{"project", `
+Rules:
+  - {Id: allow-up, Operation: "^orchestrate.up$", Authorize: allow}
+  - {Id: deny-all, Operation: "*", Authorize: deny}
+`},
+			},
+			combine:  SECURITY_COMBINE_DENY_OVERRIDES,
+			decision: SECURITY_TRACE_DECISION_DENY,
+			rule:     "project:deny-all",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := SecurityConfigWrapperAuthorizeYmlHandler{}
+			for _, scope := range test.scopes {
+				definition := SecurityConfigWrapperAuthorizeYmlDefinition{}
+				if err := yaml.Unmarshal([]byte(scope.source), &definition); err != nil {
+					t.Fatal(err)
+				}
+				handler.Add(scope.scope, definition)
+			}
+
+			op, err := New_securityExplainTarget("orchestrate.up", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			trace := SecurityAuthorizeTrace{}
+			handler.Authorize(op, SecurityActor{Id: "alice"}, nil, &trace)
+
+			if trace.Combine != test.combine {
+				t.Errorf("expected combine %s, got %s", test.combine, trace.Combine)
+			}
+			if trace.Decision != test.decision {
+				t.Errorf("expected decision %s, got %s", test.decision, trace.Decision)
+			}
+			if trace.DecidingRule != test.rule {
+				t.Errorf("expected deciding rule %q, got %q", test.rule, trace.DecidingRule)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package configwrapper
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+
+	api_security "github.com/wunderkraut/radi-api/operation/security"
+)
+
+/**
+ * Password authentication against a credentials file, which
+ * holds bcrypt or argon2id password hashes.  Credentials are
+ * only read from the user scope, so that they are never kept
+ * in a project that may be shared.  Credentials only give
+ * the identity of a user, as every user controls their own
+ * user scope, so groups and roles come from the project
+ * scope, @see security_yaml_roles.go
+ *
+ *   Users:
+ *     jane:
+ *       Label: Jane Doe
+ *       Hash: $2a$10$...
+ */
+
+const (
+	// The Config key for credentials
+	CONFIG_KEY_SECURITY_CREDENTIALS = "credentials"
+	// The only config scope that credentials are read from
+	SECURITY_CREDENTIALS_SCOPE = "user"
+)
+
+// Something which can authenticate a user by id and password
+type SecurityAuthenticator interface {
+	Authenticate(id string, password string) (api_security.SecurityUser, error)
+}
+
+// Something which can get a user from the credentials by id, such as to reload the user of a session
+type SecurityCredentialUsers interface {
+	CredentialUser(id string) (api_security.SecurityUser, error)
+}
+
+// The error returned for any failed authentication, so that failures do not say which part was wrong
+var ErrSecurityAuthenticationFailed = errors.New("Authentication failed")
+
+// Credentials definition from yml
+type SecurityCredentialsYml struct {
+	Users map[string]SecurityCredentialYml `yaml:"Users"`
+}
+
+// Credentials for a single user
+type SecurityCredentialYml struct {
+	Label string `yaml:"Label,omitempty"`
+	Hash  string `yaml:"Hash"`
+}
+
+// Authenticate a user by id and password against the user scope credentials (SecurityAuthenticator interface)
+func (security *SecurityConfigWrapperYml) Authenticate(id string, password string) (api_security.SecurityUser, error) {
+	credentials, err := security.loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	credential, found := credentials.Users[id]
+	if !found || credential.Hash == "" {
+		log.WithFields(log.Fields{"user": id}).Warn("Authentication failed, as the user has no credentials")
+		return nil, ErrSecurityAuthenticationFailed
+	}
+	if err := securityCheckPasswordHash(credential.Hash, password); err != nil {
+		log.WithError(err).WithFields(log.Fields{"user": id}).Warn("Authentication failed")
+		return nil, ErrSecurityAuthenticationFailed
+	}
+	return credential.SecurityUser(id), nil
+}
+
+// Get a user with credentials by id, as it is now in the user scope credentials (SecurityCredentialUsers interface)
+func (security *SecurityConfigWrapperYml) CredentialUser(id string) (api_security.SecurityUser, error) {
+	credentials, err := security.loadCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	credential, found := credentials.Users[id]
+	if !found || credential.Hash == "" {
+		log.WithFields(log.Fields{"user": id}).Warn("The user no longer has credentials")
+		return nil, ErrSecurityAuthenticationFailed
+	}
+	return credential.SecurityUser(id), nil
+}
+
+// Convert the credentials for a user into a SecurityUser, with only its identity
+func (credential SecurityCredentialYml) SecurityUser(id string) api_security.SecurityUser {
+	user := &SecurityConfigWrapperUserYmlDefinition{
+		UserId:    id,
+		UserLabel: credential.Label,
+	}
+	return user.SecurityUser()
+}
+
+// Load the credentials from the user scope
+func (security *SecurityConfigWrapperYml) loadCredentials() (SecurityCredentialsYml, error) {
+	credentials := SecurityCredentialsYml{}
+
+	sources, err := security.wrapper.Get(CONFIG_KEY_SECURITY_CREDENTIALS)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"config-key": CONFIG_KEY_SECURITY_CREDENTIALS}).Warn("Config wrapper could not load any credentials")
+		return credentials, ErrSecurityAuthenticationFailed
+	}
+	for _, scope := range sources.Order() {
+		if scope != SECURITY_CREDENTIALS_SCOPE {
+			log.WithFields(log.Fields{"scope": scope}).Warn("Ignoring credentials outside of the " + SECURITY_CREDENTIALS_SCOPE + " scope")
+		}
+	}
+
+	scopedSource, found := sources.Get(SECURITY_CREDENTIALS_SCOPE)
+	if !found {
+		return credentials, ErrSecurityAuthenticationFailed
+	}
+	if err := yaml.Unmarshal(scopedSource, &credentials); err != nil {
+		log.WithError(err).Error("SecurityConfigWrapperYml Couldn't unmarshall credentials yml")
+		return credentials, ErrSecurityAuthenticationFailed
+	}
+	return credentials, nil
+}
+
+// Check a password against a bcrypt or argon2id hash
+func securityCheckPasswordHash(hash string, password string) error {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return securityCheckArgon2Hash(hash, password)
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	default:
+		return errors.New("unknown password hash format, expected bcrypt or argon2id")
+	}
+}
+
+// Check a password against an argon2id hash, in the $argon2id$v=19$m=65536,t=3,p=4$salt$key form
+func securityCheckArgon2Hash(hash string, password string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return errors.New("unsupported argon2id hash version")
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil || iterations < 1 || threads < 1 {
+		return errors.New("invalid argon2id hash parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return errors.New("invalid argon2id hash salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) < 16 { // an empty key would match any password
+		return errors.New("invalid argon2id hash key")
+	}
+
+	compared := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, compared) != 1 {
+		return errors.New("password does not match")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package configwrapper
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Make an argon2id hash with small parameters, so that tests run quickly
+func securityTestArgon2Hash(password string, salt string) string {
+	key := argon2.IDKey([]byte(password), []byte(salt), 1, 64, 1, 32)
+	return fmt.Sprintf("$argon2id$v=%d$m=64,t=1,p=1$%s$%s", argon2.Version, base64.RawStdEncoding.EncodeToString([]byte(salt)), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestSecurityCheckArgon2Hash(t *testing.T) {
+	hash := securityTestArgon2Hash("secret", "somesalt")
+	salt := base64.RawStdEncoding.EncodeToString([]byte("somesalt"))
+	key := base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("secret"), []byte("somesalt"), 1, 64, 1, 32))
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		err      bool
+	}{
+		{name: "matching password", hash: hash, password: "secret"},
+		{name: "wrong password", hash: hash, password: "Secret", err: true},
+		{name: "empty password", hash: hash, password: "", err: true},
+		{name: "too few parts", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt, password: "secret", err: true},
+		{name: "unsupported version", hash: "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key, password: "secret", err: true},
+		{name: "invalid parameters", hash: "$argon2id$v=19$m=64;t=1;p=1$" + salt + "$" + key, password: "secret", err: true},
+		{name: "no threads", hash: "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key, password: "secret", err: true},
+		{name: "no iterations", hash: "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key, password: "secret", err: true},
+		{name: "different parameters", hash: "$argon2id$v=19$m=64,t=2,p=1$" + salt + "$" + key, password: "secret", err: true},
+		{name: "invalid salt", hash: "$argon2id$v=19$m=64,t=1,p=1$!!$" + key, password: "secret", err: true},
+		{name: "invalid key", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$!!", password: "secret", err: true},
+		{name: "empty key", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$", password: "anything", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := securityCheckArgon2Hash(test.hash, test.password)
+			if (err != nil) != test.err {
+				t.Errorf("expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestSecurityCheckPasswordHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		err      bool
+	}{
+		{name: "argon2id", hash: securityTestArgon2Hash("secret", "somesalt"), password: "secret"},
+		{name: "bcrypt", hash: "$2a$04$ywq7ueHD5RHmEM0H64o50eTce977Qdd6.1g5tGlH3uPz0cEHjD90y", password: "secret"},
+		{name: "bcrypt wrong password", hash: "$2a$04$ywq7ueHD5RHmEM0H64o50eTce977Qdd6.1g5tGlH3uPz0cEHjD90y", password: "other", err: true},
+		{name: "plain text", hash: "secret", password: "secret", err: true},
+		{name: "unknown format", hash: "$1$salt$hash", password: "secret", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := securityCheckPasswordHash(test.hash, test.password)
+			if (err != nil) != test.err {
+				t.Errorf("expected error %v, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package configwrapper
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	api_operation "github.com/wunderkraut/radi-api/operation"
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_result "github.com/wunderkraut/radi-api/result"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+
+	api_security "github.com/wunderkraut/radi-api/operation/security"
+)
+
+/**
+ * Authorization explain traces, which record each rule
+ * evaluated when authorizing an operation, so that a
+ * denied user can see why.
+ */
+
+const (
+	// Decisions and rule outcomes in a trace
+	SECURITY_TRACE_DECISION_ALLOW = "allow"
+	SECURITY_TRACE_DECISION_DENY  = "deny"
+	SECURITY_TRACE_DECISION_NONE  = "none"
+
+	// Operation id for the explain operation
+	OPERATION_ID_SECURITY_EXPLAIN = "security.explain"
+
+	// Properties for the trace and explain operation
+	OPERATION_PROPERTY_SECURITY_AUTHORIZE_TRACE   = "security.authorize.trace"
+	OPERATION_PROPERTY_SECURITY_EXPLAIN_OPERATION = "security.explain.operation"
+	OPERATION_PROPERTY_SECURITY_EXPLAIN_PROPERTY  = "security.explain.property"
+	OPERATION_PROPERTY_SECURITY_EXPLAIN_REPORT    = "security.explain.report"
+)
+
+// A trace of the authorization of an operation
+type SecurityAuthorizeTrace struct {
+	Operation    string              // the id of the authorized operation
+	Actor        SecurityActor       // the user being authorized
+	Combine      string              // the rule combining algorithm
+	Rules        []SecurityRuleTrace // every rule evaluated, in order
+	Decision     string              // the aggregate decision: allow, deny or none
+	DecidingRule string              // the id of the rule which decided, if any
+	Message      string              // the message of the deciding rule
+}
+
+// A trace of the evaluation of a single rule
+type SecurityRuleTrace struct {
+	Scope            string                  // the config scope that the rule came from
+	Rule             string                  // the rule id
+	Operation        string                  // the rule operation match
+	Priority         int                     // the rule priority
+	OperationMatched bool                    // did the operation id match
+	ActorMatched     bool                    // did the rule User, Group or Role match
+	Properties       []SecurityPropertyTrace // each property comparison
+	Outcome          string                  // the rule outcome: allow, deny or none
+	Reason           string                  // why the rule had the outcome
+}
+
+// A trace of a single rule property comparison
+type SecurityPropertyTrace struct {
+	Property string   // the property id
+	Expected []string // the values that the rule matches
+	Value    string   // the operation property value, if found
+	Found    bool     // did the operation have the property
+	Matched  bool     // did the value match
+	Error    string   // why the value could not be matched, if it could not
+}
+
+// Convert an auth value to a trace outcome
+func securityTraceOutcome(authValue int) string {
+	switch {
+	case authValue > 0:
+		return SECURITY_TRACE_DECISION_ALLOW
+	case authValue < 0:
+		return SECURITY_TRACE_DECISION_DENY
+	default:
+		return SECURITY_TRACE_DECISION_NONE
+	}
+}
+
+// Describe the trace as readable lines
+func (trace SecurityAuthorizeTrace) Lines() []string {
+	lines := []string{}
+	for _, rule := range trace.Rules {
+		line := rule.Scope + ":" + rule.Rule + " [" + rule.Operation + "]"
+		if rule.Priority != 0 {
+			line += " priority " + strconv.Itoa(rule.Priority)
+		}
+		lines = append(lines, line+" => "+rule.Outcome+" ("+rule.Reason+")")
+		for _, prop := range rule.Properties {
+			line := "    " + prop.Property + " in [" + strings.Join(prop.Expected, ", ") + "]: "
+			switch {
+			case !prop.Found:
+				line += "not found"
+			case prop.Error != "":
+				line += "error: " + prop.Error
+			case prop.Matched:
+				line += strconv.Quote(prop.Value) + " matched"
+			default:
+				line += strconv.Quote(prop.Value) + " did not match"
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	decision := "Decision (" + trace.Combine + ") for " + trace.Operation
+	if trace.Actor.Id != "" {
+		decision += " by user " + trace.Actor.Id
+	}
+	decision += ": " + trace.Decision
+	if trace.DecidingRule != "" {
+		decision += " by rule " + trace.DecidingRule
+	}
+	if trace.Message != "" {
+		decision += ": " + trace.Message
+	}
+	return append(lines, decision)
+}
+
+/**
+ * Operations
+ */
+
+// Explain how an operation is authorized
+//
+// If Operations is given, then id=value property values are converted to
+// the property types of the operation being explained, so that number,
+// duration and bool rule matches compare them as the real values.
+type SecurityExplainOperation struct {
+	SecurityWrapperBaseOperation
+	Operations func() api_operation.Operations
+}
+
+// Id the operation
+func (explain *SecurityExplainOperation) Id() string {
+	return OPERATION_ID_SECURITY_EXPLAIN
+}
+
+// Label the operation
+func (explain *SecurityExplainOperation) Label() string {
+	return "Explain authorization"
+}
+
+// Description for the operation
+func (explain *SecurityExplainOperation) Description() string {
+	return "Explain how the authorization rules decide for an operation."
+}
+
+// Help text for the operation
+func (explain *SecurityExplainOperation) Help() string {
+	return "Give the operation id to explain, and property values as id=value to compare against rule property matches.  Values take the type of the operation's property, where the operation is known, and list values are comma separated.  Every evaluated rule is listed with its scope and outcome, followed by the decision."
+}
+
+// Usage for the operation
+func (explain *SecurityExplainOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+// Validate the operation
+func (explain *SecurityExplainOperation) Validate() api_result.Result {
+	return api_result.MakeSuccessfulResult()
+}
+
+// Get properties
+func (explain *SecurityExplainOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&api_security.SecurityAuthorizationOperationProperty{}))
+	props.Add(api_property.Property(&SecurityExplainOperationProperty{}))
+	props.Add(api_property.Property(&SecurityExplainPropertyProperty{}))
+	props.Add(api_property.Property(&api_security.SecurityAuthorizationRuleResultProperty{}))
+	props.Add(api_property.Property(&SecurityAuthorizeTraceProperty{}))
+	props.Add(api_property.Property(&SecurityExplainReportProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (explain *SecurityExplainOperation) Exec(props api_property.Properties) api_result.Result {
+	res := api_result.New_StandardResult()
+
+	var target api_operation.Operation
+	if prop, found := props.Get((&api_security.SecurityAuthorizationOperationProperty{}).Id()); found {
+		target, _ = prop.Get().(api_operation.Operation)
+	}
+	if target == nil {
+		id := ""
+		if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_EXPLAIN_OPERATION); found {
+			id, _ = prop.Get().(string)
+		}
+		values := []string{}
+		if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_EXPLAIN_PROPERTY); found {
+			values, _ = prop.Get().([]string)
+		}
+
+		var operation api_operation.Operation
+		if explain.Operations != nil {
+			operation, _ = explain.Operations().Get(id)
+		}
+
+		if stub, err := New_securityExplainTarget(id, values, operation); err == nil {
+			target = stub
+		} else {
+			res.MarkFailed()
+			res.AddError(err)
+			res.MarkFinished()
+			return res.Result()
+		}
+	}
+
+	ruleResult, trace := explain.SecurityConfigWrapper().ExplainOperation(target)
+
+	if prop, found := props.Get(api_security.SECURITY_AUTHORIZATION_RULERESULT_PROPERTY_KEY); found {
+		prop.Set(ruleResult)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_AUTHORIZE_TRACE); found {
+		prop.Set(trace)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_EXPLAIN_REPORT); found {
+		prop.Set(trace.Lines())
+	}
+
+	res.MarkSuccess()
+	res.MarkFinished()
+	return res.Result()
+}
+
+// Make an operation to explain, from an id and id=value property strings
+//
+// If the real operation is given, then each value is converted to the type
+// of its property, otherwise all values are strings.
+func New_securityExplainTarget(id string, values []string, operation api_operation.Operation) (*securityExplainTarget, error) {
+	if id == "" {
+		return nil, errors.New("An operation, or an operation id, is needed to explain authorization")
+	}
+
+	var operationProps api_property.Properties
+	if operation != nil {
+		operationProps = operation.Properties()
+	}
+
+	props := api_property.New_SimplePropertiesEmpty()
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.New("Explain property values must be id=value: " + value)
+		}
+
+		if operationProps == nil {
+			prop := &securityExplainTargetProperty{id: parts[0]}
+			prop.Set(parts[1])
+			props.Add(api_property.Property(prop))
+			continue
+		}
+
+		realProp, found := operationProps.Get(parts[0])
+		if !found {
+			return nil, errors.New("Operation " + id + " has no property " + parts[0])
+		}
+		prop := realProp.Copy()
+		converted, err := securityExplainValue(prop.Get(), parts[1])
+		if err != nil {
+			return nil, errors.New("Explain property " + parts[0] + ": " + err.Error())
+		}
+		if !prop.Set(converted) {
+			return nil, errors.New("Explain property " + parts[0] + " did not accept the value " + strconv.Quote(parts[1]))
+		}
+		props.Add(prop)
+	}
+
+	return &securityExplainTarget{id: id, props: props.Properties()}, nil
+}
+
+// Convert an explain string value to the type of an existing property value
+func securityExplainValue(existing interface{}, value string) (interface{}, error) {
+	switch existing.(type) {
+	case nil, string:
+		return value, nil
+	case []byte:
+		return []byte(value), nil
+	case []string:
+		if value == "" {
+			return []string{}, nil
+		}
+		return strings.Split(value, ","), nil
+	case bool:
+		return strconv.ParseBool(value)
+	case int:
+		converted, err := strconv.ParseInt(value, 10, 0)
+		return int(converted), err
+	case int32:
+		converted, err := strconv.ParseInt(value, 10, 32)
+		return int32(converted), err
+	case int64:
+		return strconv.ParseInt(value, 10, 64)
+	case float32:
+		converted, err := strconv.ParseFloat(value, 32)
+		return float32(converted), err
+	case float64:
+		return strconv.ParseFloat(value, 64)
+	case time.Duration:
+		return time.ParseDuration(value)
+	default:
+		return nil, fmt.Errorf("values of type %T cannot be given as id=value", existing)
+	}
+}
+
+// A stand in operation to explain, which has only an id and properties
+type securityExplainTarget struct {
+	id    string
+	props api_property.Properties
+}
+
+// Id the operation
+func (target *securityExplainTarget) Id() string {
+	return target.id
+}
+
+// Label the operation
+func (target *securityExplainTarget) Label() string {
+	return target.id
+}
+
+// Description for the operation
+func (target *securityExplainTarget) Description() string {
+	return "Stand in for " + target.id + " when explaining authorization."
+}
+
+// Help text for the operation
+func (target *securityExplainTarget) Help() string {
+	return ""
+}
+
+// Usage for the operation
+func (target *securityExplainTarget) Usage() api_usage.Usage {
+	return api_operation.Usage_Internal()
+}
+
+// Validate the operation
+func (target *securityExplainTarget) Validate() api_result.Result {
+	return api_result.MakeSuccessfulResult()
+}
+
+// Get properties
+func (target *securityExplainTarget) Properties() api_property.Properties {
+	return target.props
+}
+
+// The stand in operation is never executed
+func (target *securityExplainTarget) Exec(props api_property.Properties) api_result.Result {
+	res := api_result.New_StandardResult()
+	res.MarkFailed()
+	res.AddError(errors.New("Operation " + target.id + " is a stand in for explaining authorization, and cannot be executed"))
+	res.MarkFinished()
+	return res.Result()
+}
+
+// A string property of a stand in operation
+type securityExplainTargetProperty struct {
+	api_property.StringProperty
+	id string
+}
+
+// Id for the Property
+func (prop *securityExplainTargetProperty) Id() string {
+	return prop.id
+}
+
+// Label for the Property
+func (prop *securityExplainTargetProperty) Label() string {
+	return prop.id
+}
+
+// Description for the Property
+func (prop *securityExplainTargetProperty) Description() string {
+	return "Explained value for " + prop.id + "."
+}
+
+// Is the Property internal only
+func (prop *securityExplainTargetProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (prop *securityExplainTargetProperty) Copy() api_property.Property {
+	copied := &securityExplainTargetProperty{id: prop.id}
+	copied.Set(prop.Get())
+	return api_property.Property(copied)
+}
+
+/**
+ * Properties
+ */
+
+// Property holding an authorization trace
+type SecurityAuthorizeTraceProperty struct {
+	value SecurityAuthorizeTrace
+}
+
+// Id for the Property
+func (trace *SecurityAuthorizeTraceProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_AUTHORIZE_TRACE
+}
+
+// Give an idea of what type of value the property consumes
+func (trace *SecurityAuthorizeTraceProperty) Type() string {
+	return "handler/configwrapper.SecurityAuthorizeTrace"
+}
+
+// Label for the Property
+func (trace *SecurityAuthorizeTraceProperty) Label() string {
+	return "Authorization trace"
+}
+
+// Description for the Property
+func (trace *SecurityAuthorizeTraceProperty) Description() string {
+	return "Each authorization rule evaluated, and the decision that they made."
+}
+
+// Is the Property internal only
+func (trace *SecurityAuthorizeTraceProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Property Accessors
+func (trace *SecurityAuthorizeTraceProperty) Get() interface{} {
+	return interface{}(trace.value)
+}
+func (trace *SecurityAuthorizeTraceProperty) Set(value interface{}) bool {
+	if converted, ok := value.(SecurityAuthorizeTrace); ok {
+		trace.value = converted
+		return true
+	} else {
+		log.WithFields(log.Fields{"value": value}).Error("Could not assign Property value, because the passed parameter was the wrong type. Expected configwrapper.SecurityAuthorizeTrace")
+		return false
+	}
+}
+
+// Copy the property
+func (trace *SecurityAuthorizeTraceProperty) Copy() api_property.Property {
+	prop := &SecurityAuthorizeTraceProperty{}
+	prop.Set(trace.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the id of an operation to explain
+type SecurityExplainOperationProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (operation *SecurityExplainOperationProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_EXPLAIN_OPERATION
+}
+
+// Label for the Property
+func (operation *SecurityExplainOperationProperty) Label() string {
+	return "Operation id"
+}
+
+// Description for the Property
+func (operation *SecurityExplainOperationProperty) Description() string {
+	return "The id of the operation to explain authorization for."
+}
+
+// Is the Property internal only
+func (operation *SecurityExplainOperationProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (operation *SecurityExplainOperationProperty) Copy() api_property.Property {
+	prop := &SecurityExplainOperationProperty{}
+	prop.Set(operation.Get())
+	return api_property.Property(prop)
+}
+
+// Property for id=value property values of an operation to explain
+type SecurityExplainPropertyProperty struct {
+	api_property.StringSliceProperty
+}
+
+// Id for the Property
+func (property *SecurityExplainPropertyProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_EXPLAIN_PROPERTY
+}
+
+// Label for the Property
+func (property *SecurityExplainPropertyProperty) Label() string {
+	return "Property values"
+}
+
+// Description for the Property
+func (property *SecurityExplainPropertyProperty) Description() string {
+	return "Property values of the explained operation, as id=value."
+}
+
+// Is the Property internal only
+func (property *SecurityExplainPropertyProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (property *SecurityExplainPropertyProperty) Copy() api_property.Property {
+	prop := &SecurityExplainPropertyProperty{}
+	prop.Set(property.Get())
+	return api_property.Property(prop)
+}
+
+// Property for a readable explain report
+type SecurityExplainReportProperty struct {
+	api_property.StringSliceProperty
+}
+
+// Id for the Property
+func (report *SecurityExplainReportProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_EXPLAIN_REPORT
+}
+
+// Label for the Property
+func (report *SecurityExplainReportProperty) Label() string {
+	return "Explanation"
+}
+
+// Description for the Property
+func (report *SecurityExplainReportProperty) Description() string {
+	return "Each evaluated rule and its outcome, followed by the decision."
+}
+
+// Is the Property internal only
+func (report *SecurityExplainReportProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (report *SecurityExplainReportProperty) Copy() api_property.Property {
+	prop := &SecurityExplainReportProperty{}
+	prop.Set(report.Get())
+	return api_property.Property(prop)
+}
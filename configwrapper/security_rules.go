@@ -0,0 +1,866 @@
+package configwrapper
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	api_operation "github.com/wunderkraut/radi-api/operation"
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_result "github.com/wunderkraut/radi-api/result"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+)
+
+/**
+ * Authorization rule management, which edits the rules
+ * of a single authorize.yml scope, and saves the scope.
+ */
+
+const (
+	// Operation ids for the rule operations
+	OPERATION_ID_SECURITY_RULE_LIST   = "security.rule.list"
+	OPERATION_ID_SECURITY_RULE_ADD    = "security.rule.add"
+	OPERATION_ID_SECURITY_RULE_REMOVE = "security.rule.remove"
+	OPERATION_ID_SECURITY_RULE_MOVE   = "security.rule.move"
+	OPERATION_ID_SECURITY_RULE_LINT   = "security.rule.lint"
+
+	// Properties for the rule operations
+	OPERATION_PROPERTY_SECURITY_RULE_SCOPE     = "security.rule.scope"
+	OPERATION_PROPERTY_SECURITY_RULE_ID        = "security.rule.id"
+	OPERATION_PROPERTY_SECURITY_RULE_OPERATION = "security.rule.operation"
+	OPERATION_PROPERTY_SECURITY_RULE_AUTHORIZE = "security.rule.authorize"
+	OPERATION_PROPERTY_SECURITY_RULE_AGGREGATE = "security.rule.aggregate"
+	OPERATION_PROPERTY_SECURITY_RULE_MESSAGE   = "security.rule.message"
+	OPERATION_PROPERTY_SECURITY_RULE_PROPERTY  = "security.rule.property"
+	OPERATION_PROPERTY_SECURITY_RULE_POSITION  = "security.rule.position"
+	OPERATION_PROPERTY_SECURITY_RULE_RULES     = "security.rule.rules"
+	OPERATION_PROPERTY_SECURITY_RULE_ISSUES    = "security.rule.issues"
+)
+
+/**
+ * Rule management for the yml security wrapper
+ */
+
+// Get the invalid authorization config found when the rules were loaded
+func (security *SecurityConfigWrapperYml) AuthorizeIssues() []SettingLintIssue {
+	security.safe()
+	return security.authIssues
+}
+
+// List the authorization scopes, in the order that they were loaded
+func (security *SecurityConfigWrapperYml) AuthorizeScopes() []string {
+	security.safe()
+	return append([]string{}, security.authHandler.Order()...)
+}
+
+// Get copies of the rules written in a scope, without defaults applied
+func (security *SecurityConfigWrapperYml) AuthorizeScopeRules(scope string) []SecurityConfigWrapperAuthorizeYmlRule {
+	security.safe()
+	rules := []SecurityConfigWrapperAuthorizeYmlRule{}
+	if definition, found := security.authHandler.Get(scope); found {
+		for _, rule := range definition.SourceRules {
+			rules = append(rules, *rule)
+		}
+	}
+	return rules
+}
+
+// Add a rule to a scope (the default scope if empty) at a position (a negative position appends), and save
+func (security *SecurityConfigWrapperYml) AddAuthorizeRule(scope string, rule SecurityConfigWrapperAuthorizeYmlRule, position int) error {
+	if scope == "" {
+		scope = security.DefaultScope()
+	}
+	if rule.Id == "" {
+		return errors.New("An authorization rule needs an Id")
+	}
+	if rule.Operation == "" {
+		return errors.New("Authorization rule " + rule.Id + " needs an Operation match")
+	}
+
+	return security.editAuthorizeScope(scope, func(definition *SecurityConfigWrapperAuthorizeYmlDefinition) error {
+		if index := definition.ruleIndex(rule.Id); index >= 0 {
+			return errors.New("Authorization rule " + rule.Id + " already exists in scope " + scope)
+		}
+		compiled := definition.applyDefaults(rule)
+		if err := compiled.compile(); err != nil {
+			return errors.New("Authorization rule " + rule.Id + " is invalid: " + err.Error())
+		}
+		if position < 0 || position > len(definition.SourceRules) {
+			position = len(definition.SourceRules)
+		}
+		rules := append([]*SecurityConfigWrapperAuthorizeYmlRule{}, definition.SourceRules[:position]...)
+		rules = append(rules, &rule)
+		definition.SourceRules = append(rules, definition.SourceRules[position:]...)
+		return nil
+	})
+}
+
+// Remove a rule from a scope, and save
+func (security *SecurityConfigWrapperYml) RemoveAuthorizeRule(scope string, id string) error {
+	if scope == "" {
+		scope = security.DefaultScope()
+	}
+	return security.editAuthorizeScope(scope, func(definition *SecurityConfigWrapperAuthorizeYmlDefinition) error {
+		index := definition.ruleIndex(id)
+		if index < 0 {
+			return errors.New("Authorization rule " + id + " was not found in scope " + scope)
+		}
+		definition.SourceRules = append(definition.SourceRules[:index], definition.SourceRules[index+1:]...)
+		return nil
+	})
+}
+
+// Move a rule to a new position in its scope, and save
+func (security *SecurityConfigWrapperYml) MoveAuthorizeRule(scope string, id string, position int) error {
+	if scope == "" {
+		scope = security.DefaultScope()
+	}
+	return security.editAuthorizeScope(scope, func(definition *SecurityConfigWrapperAuthorizeYmlDefinition) error {
+		index := definition.ruleIndex(id)
+		if index < 0 {
+			return errors.New("Authorization rule " + id + " was not found in scope " + scope)
+		}
+		if position < 0 || position >= len(definition.SourceRules) {
+			return errors.New("Position " + strconv.Itoa(position) + " is outside of the rules in scope " + scope)
+		}
+		rule := definition.SourceRules[index]
+		rules := append([]*SecurityConfigWrapperAuthorizeYmlRule{}, definition.SourceRules[:index]...)
+		rules = append(rules, definition.SourceRules[index+1:]...)
+		moved := append([]*SecurityConfigWrapperAuthorizeYmlRule{}, rules[:position]...)
+		moved = append(moved, rule)
+		definition.SourceRules = append(moved, rules[position:]...)
+		return nil
+	})
+}
+
+// Edit the definition for a scope, then save it, reloading if the edit or save fails
+func (security *SecurityConfigWrapperYml) editAuthorizeScope(scope string, edit func(definition *SecurityConfigWrapperAuthorizeYmlDefinition) error) error {
+	security.safe()
+
+	if _, found := security.authHandler.Get(scope); !found {
+		security.authHandler.Add(scope, SecurityConfigWrapperAuthorizeYmlDefinition{})
+	}
+	definition, _ := security.authHandler.Get(scope)
+
+	err := edit(definition)
+	definition.rules = nil // recompile the changed rules when next used
+	if err == nil {
+		if !projectComponentsContains(security.authDirty, scope) {
+			security.authDirty = append(security.authDirty, scope)
+		}
+		err = security.Save()
+	}
+	if err != nil {
+		security.LoadAuthorize() // drop the failed change
+	}
+	return err
+}
+
+// Find the index of a rule by id, or -1
+func (definition *SecurityConfigWrapperAuthorizeYmlDefinition) ruleIndex(id string) int {
+	for index, rule := range definition.SourceRules {
+		if rule.Id == id {
+			return index
+		}
+	}
+	return -1
+}
+
+// Describe a rule as a single readable line
+func (ymlRule SecurityConfigWrapperAuthorizeYmlRule) String() string {
+	parts := []string{ymlRule.Id, "operation=" + ymlRule.Operation}
+	if ymlRule.Authorize != "" {
+		parts = append(parts, "authorize="+ymlRule.Authorize)
+	}
+	if ymlRule.Aggregate != "" {
+		parts = append(parts, "aggregate="+ymlRule.Aggregate)
+	}
+	if ymlRule.Priority != 0 {
+		parts = append(parts, "priority="+strconv.Itoa(ymlRule.Priority))
+	}
+	if len(ymlRule.Users) > 0 {
+		parts = append(parts, "user="+strings.Join(ymlRule.Users, ","))
+	}
+	if len(ymlRule.Groups) > 0 {
+		parts = append(parts, "group="+strings.Join(ymlRule.Groups, ","))
+	}
+	if len(ymlRule.Roles) > 0 {
+		parts = append(parts, "role="+strings.Join(ymlRule.Roles, ","))
+	}
+	if ymlRule.When != nil {
+		parts = append(parts, "when")
+	}
+	if ymlRule.Actor != nil {
+		parts = append(parts, "actor("+ymlRule.Actor.String()+")")
+	}
+	propIds := []string{}
+	for propId := range ymlRule.Properties {
+		propIds = append(propIds, propId)
+	}
+	sort.Strings(propIds)
+	for _, propId := range propIds {
+		parts = append(parts, "property:"+propId+"="+strings.Join(ymlRule.Properties[propId], ","))
+	}
+	if ymlRule.Message != "" {
+		parts = append(parts, "message="+strconv.Quote(ymlRule.Message))
+	}
+	return strings.Join(parts, " ")
+}
+
+/**
+ * Operations
+ */
+
+// Base for the rule operations, which holds the wrapper and common properties
+type SecurityRuleBaseOperation struct {
+	Wrapper *SecurityConfigWrapperYml
+}
+
+// Usage for the operation
+func (base SecurityRuleBaseOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+// Validate the operation
+func (base SecurityRuleBaseOperation) Validate() api_result.Result {
+	return api_result.MakeSuccessfulResult()
+}
+
+// Finish a result, failing it if there was an error
+func (base SecurityRuleBaseOperation) finish(err error) api_result.Result {
+	res := api_result.New_StandardResult()
+	if err == nil {
+		res.MarkSuccess()
+	} else {
+		res.MarkFailed()
+		res.AddError(err)
+	}
+	res.MarkFinished()
+	return res.Result()
+}
+
+// Read the rule scope, id and position properties
+func (base SecurityRuleBaseOperation) readTarget(props api_property.Properties) (string, string, int, error) {
+	scope, id, position := "", "", -1
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_RULE_SCOPE); found {
+		scope, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_RULE_ID); found {
+		id, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_RULE_POSITION); found {
+		if positionString, _ := prop.Get().(string); positionString != "" {
+			converted, err := strconv.Atoi(positionString)
+			if err != nil {
+				return scope, id, position, errors.New("Rule position must be a number: " + positionString)
+			}
+			position = converted
+		}
+	}
+	return scope, id, position, nil
+}
+
+// A Security Rule List operation
+type SecurityRuleListOperation struct {
+	SecurityRuleBaseOperation
+}
+
+// Id the operation
+func (list SecurityRuleListOperation) Id() string {
+	return OPERATION_ID_SECURITY_RULE_LIST
+}
+
+// Label the operation
+func (list SecurityRuleListOperation) Label() string {
+	return "List authorization rules"
+}
+
+// Description for the operation
+func (list SecurityRuleListOperation) Description() string {
+	return "List the authorization rules written in each scope, in order."
+}
+
+// Help text for the operation
+func (list SecurityRuleListOperation) Help() string {
+	return "Rules are listed as they are written in config, without the defaults from the scope Settings.  Limit the list to a single scope with the scope property."
+}
+
+// Get properties
+func (list SecurityRuleListOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SecurityRuleScopeProperty{}))
+	props.Add(api_property.Property(&SecurityRuleRulesProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (list SecurityRuleListOperation) Exec(props api_property.Properties) api_result.Result {
+	scope, _, _, _ := list.readTarget(props)
+	rulesProp, _ := props.Get(OPERATION_PROPERTY_SECURITY_RULE_RULES)
+
+	lines := []string{}
+	for _, listScope := range list.Wrapper.AuthorizeScopes() {
+		if scope != "" && scope != listScope {
+			continue
+		}
+		for index, rule := range list.Wrapper.AuthorizeScopeRules(listScope) {
+			lines = append(lines, listScope+"["+strconv.Itoa(index)+"] "+rule.String())
+		}
+	}
+	rulesProp.Set(lines)
+
+	return list.finish(nil)
+}
+
+// A Security Rule Lint operation
+type SecurityRuleLintOperation struct {
+	SecurityRuleBaseOperation
+}
+
+// Id the operation
+func (lint SecurityRuleLintOperation) Id() string {
+	return OPERATION_ID_SECURITY_RULE_LINT
+}
+
+// Label the operation
+func (lint SecurityRuleLintOperation) Label() string {
+	return "Lint authorization rules"
+}
+
+// Description for the operation
+func (lint SecurityRuleLintOperation) Description() string {
+	return "Check the authorization rules of each scope for syntax errors and invalid rules."
+}
+
+// Help text for the operation
+func (lint SecurityRuleLintOperation) Help() string {
+	return "Rules are read fresh from config.  An invalid rule denies the operations that it could match, so the operation fails if any error level issue is found."
+}
+
+// Get properties
+func (lint SecurityRuleLintOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SecurityRuleIssuesProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (lint SecurityRuleLintOperation) Exec(props api_property.Properties) api_result.Result {
+	issuesProp, _ := props.Get(OPERATION_PROPERTY_SECURITY_RULE_ISSUES)
+
+	lint.Wrapper.LoadAuthorize()
+
+	errorCount := 0
+	issueStrings := []string{}
+	for _, issue := range lint.Wrapper.AuthorizeIssues() {
+		if issue.Severity == SETTING_LINT_ERROR {
+			errorCount++
+		}
+		issueStrings = append(issueStrings, issue.String())
+	}
+	issuesProp.Set(issueStrings)
+
+	if errorCount > 0 {
+		return lint.finish(errors.New("Authorization rule lint found " + strconv.Itoa(errorCount) + " error(s)"))
+	}
+	return lint.finish(nil)
+}
+
+// A Security Rule Add operation
+type SecurityRuleAddOperation struct {
+	SecurityRuleBaseOperation
+}
+
+// Id the operation
+func (add SecurityRuleAddOperation) Id() string {
+	return OPERATION_ID_SECURITY_RULE_ADD
+}
+
+// Label the operation
+func (add SecurityRuleAddOperation) Label() string {
+	return "Add authorization rule"
+}
+
+// Description for the operation
+func (add SecurityRuleAddOperation) Description() string {
+	return "Add an authorization rule to a scope."
+}
+
+// Help text for the operation
+func (add SecurityRuleAddOperation) Help() string {
+	return "The rule is added to the default scope unless a scope is given, and appended unless a position is given.  Property matches are given as id=value, repeated for each value."
+}
+
+// Get properties
+func (add SecurityRuleAddOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SecurityRuleScopeProperty{}))
+	props.Add(api_property.Property(&SecurityRuleIdProperty{}))
+	props.Add(api_property.Property(&SecurityRuleOperationProperty{}))
+	props.Add(api_property.Property(&SecurityRuleAuthorizeProperty{}))
+	props.Add(api_property.Property(&SecurityRuleAggregateProperty{}))
+	props.Add(api_property.Property(&SecurityRuleMessageProperty{}))
+	props.Add(api_property.Property(&SecurityRulePropertyProperty{}))
+	props.Add(api_property.Property(&SecurityRulePositionProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (add SecurityRuleAddOperation) Exec(props api_property.Properties) api_result.Result {
+	scope, id, position, err := add.readTarget(props)
+	if err != nil {
+		return add.finish(err)
+	}
+
+	rule := SecurityConfigWrapperAuthorizeYmlRule{Id: id}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_RULE_OPERATION); found {
+		rule.Operation, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_RULE_AUTHORIZE); found {
+		rule.Authorize, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_RULE_AGGREGATE); found {
+		rule.Aggregate, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_RULE_MESSAGE); found {
+		rule.Message, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(OPERATION_PROPERTY_SECURITY_RULE_PROPERTY); found {
+		matches, _ := prop.Get().([]string)
+		for _, match := range matches {
+			parts := strings.SplitN(match, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return add.finish(errors.New("Rule property match must be id=value: " + match))
+			}
+			if rule.Properties == nil {
+				rule.Properties = map[string][]string{}
+			}
+			rule.Properties[parts[0]] = append(rule.Properties[parts[0]], parts[1])
+		}
+	}
+
+	return add.finish(add.Wrapper.AddAuthorizeRule(scope, rule, position))
+}
+
+// A Security Rule Remove operation
+type SecurityRuleRemoveOperation struct {
+	SecurityRuleBaseOperation
+}
+
+// Id the operation
+func (remove SecurityRuleRemoveOperation) Id() string {
+	return OPERATION_ID_SECURITY_RULE_REMOVE
+}
+
+// Label the operation
+func (remove SecurityRuleRemoveOperation) Label() string {
+	return "Remove authorization rule"
+}
+
+// Description for the operation
+func (remove SecurityRuleRemoveOperation) Description() string {
+	return "Remove an authorization rule from a scope."
+}
+
+// Help text for the operation
+func (remove SecurityRuleRemoveOperation) Help() string {
+	return "The rule is removed from the default scope unless a scope is given."
+}
+
+// Get properties
+func (remove SecurityRuleRemoveOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SecurityRuleScopeProperty{}))
+	props.Add(api_property.Property(&SecurityRuleIdProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (remove SecurityRuleRemoveOperation) Exec(props api_property.Properties) api_result.Result {
+	scope, id, _, err := remove.readTarget(props)
+	if err != nil {
+		return remove.finish(err)
+	}
+	return remove.finish(remove.Wrapper.RemoveAuthorizeRule(scope, id))
+}
+
+// A Security Rule Move operation
+type SecurityRuleMoveOperation struct {
+	SecurityRuleBaseOperation
+}
+
+// Id the operation
+func (move SecurityRuleMoveOperation) Id() string {
+	return OPERATION_ID_SECURITY_RULE_MOVE
+}
+
+// Label the operation
+func (move SecurityRuleMoveOperation) Label() string {
+	return "Move authorization rule"
+}
+
+// Description for the operation
+func (move SecurityRuleMoveOperation) Description() string {
+	return "Move an authorization rule to a new position in its scope."
+}
+
+// Help text for the operation
+func (move SecurityRuleMoveOperation) Help() string {
+	return "Positions start at 0, as shown by the rule list operation.  The rule is moved within the default scope unless a scope is given."
+}
+
+// Get properties
+func (move SecurityRuleMoveOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SecurityRuleScopeProperty{}))
+	props.Add(api_property.Property(&SecurityRuleIdProperty{}))
+	props.Add(api_property.Property(&SecurityRulePositionProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (move SecurityRuleMoveOperation) Exec(props api_property.Properties) api_result.Result {
+	scope, id, position, err := move.readTarget(props)
+	if err != nil {
+		return move.finish(err)
+	}
+	if position < 0 {
+		return move.finish(errors.New("A position is needed to move a rule"))
+	}
+	return move.finish(move.Wrapper.MoveAuthorizeRule(scope, id, position))
+}
+
+/**
+ * Properties
+ */
+
+// Property for the scope of a rule
+type SecurityRuleScopeProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (scope *SecurityRuleScopeProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_SCOPE
+}
+
+// Label for the Property
+func (scope *SecurityRuleScopeProperty) Label() string {
+	return "Scope"
+}
+
+// Description for the Property
+func (scope *SecurityRuleScopeProperty) Description() string {
+	return "The authorization config scope, such as project or user."
+}
+
+// Is the Property internal only
+func (scope *SecurityRuleScopeProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (scope *SecurityRuleScopeProperty) Copy() api_property.Property {
+	prop := &SecurityRuleScopeProperty{}
+	prop.Set(scope.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the id of a rule
+type SecurityRuleIdProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (id *SecurityRuleIdProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_ID
+}
+
+// Label for the Property
+func (id *SecurityRuleIdProperty) Label() string {
+	return "Rule id"
+}
+
+// Description for the Property
+func (id *SecurityRuleIdProperty) Description() string {
+	return "The id of the authorization rule."
+}
+
+// Is the Property internal only
+func (id *SecurityRuleIdProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (id *SecurityRuleIdProperty) Copy() api_property.Property {
+	prop := &SecurityRuleIdProperty{}
+	prop.Set(id.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the operation match of a rule
+type SecurityRuleOperationProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (operation *SecurityRuleOperationProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_OPERATION
+}
+
+// Label for the Property
+func (operation *SecurityRuleOperationProperty) Label() string {
+	return "Operation match"
+}
+
+// Description for the Property
+func (operation *SecurityRuleOperationProperty) Description() string {
+	return "A regular expression for the operation ids the rule applies to, or * for all."
+}
+
+// Is the Property internal only
+func (operation *SecurityRuleOperationProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (operation *SecurityRuleOperationProperty) Copy() api_property.Property {
+	prop := &SecurityRuleOperationProperty{}
+	prop.Set(operation.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the authorize value of a rule
+type SecurityRuleAuthorizeProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (authorize *SecurityRuleAuthorizeProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_AUTHORIZE
+}
+
+// Label for the Property
+func (authorize *SecurityRuleAuthorizeProperty) Label() string {
+	return "Authorize"
+}
+
+// Description for the Property
+func (authorize *SecurityRuleAuthorizeProperty) Description() string {
+	return "allow or deny, or leave empty to use the scope default."
+}
+
+// Is the Property internal only
+func (authorize *SecurityRuleAuthorizeProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (authorize *SecurityRuleAuthorizeProperty) Copy() api_property.Property {
+	prop := &SecurityRuleAuthorizeProperty{}
+	prop.Set(authorize.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the aggregate value of a rule
+type SecurityRuleAggregateProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (aggregate *SecurityRuleAggregateProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_AGGREGATE
+}
+
+// Label for the Property
+func (aggregate *SecurityRuleAggregateProperty) Label() string {
+	return "Aggregate"
+}
+
+// Description for the Property
+func (aggregate *SecurityRuleAggregateProperty) Description() string {
+	return "How property matches combine, such as AND, or leave empty to use the scope default."
+}
+
+// Is the Property internal only
+func (aggregate *SecurityRuleAggregateProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (aggregate *SecurityRuleAggregateProperty) Copy() api_property.Property {
+	prop := &SecurityRuleAggregateProperty{}
+	prop.Set(aggregate.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the message of a rule
+type SecurityRuleMessageProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (message *SecurityRuleMessageProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_MESSAGE
+}
+
+// Label for the Property
+func (message *SecurityRuleMessageProperty) Label() string {
+	return "Message"
+}
+
+// Description for the Property
+func (message *SecurityRuleMessageProperty) Description() string {
+	return "The message given when the rule applies, or leave empty to use the scope default."
+}
+
+// Is the Property internal only
+func (message *SecurityRuleMessageProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (message *SecurityRuleMessageProperty) Copy() api_property.Property {
+	prop := &SecurityRuleMessageProperty{}
+	prop.Set(message.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the property matches of a rule
+type SecurityRulePropertyProperty struct {
+	api_property.StringSliceProperty
+}
+
+// Id for the Property
+func (property *SecurityRulePropertyProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_PROPERTY
+}
+
+// Label for the Property
+func (property *SecurityRulePropertyProperty) Label() string {
+	return "Property matches"
+}
+
+// Description for the Property
+func (property *SecurityRulePropertyProperty) Description() string {
+	return "Operation property values the rule matches, as id=value."
+}
+
+// Is the Property internal only
+func (property *SecurityRulePropertyProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (property *SecurityRulePropertyProperty) Copy() api_property.Property {
+	prop := &SecurityRulePropertyProperty{}
+	prop.Set(property.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the position of a rule in its scope
+type SecurityRulePositionProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (position *SecurityRulePositionProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_POSITION
+}
+
+// Label for the Property
+func (position *SecurityRulePositionProperty) Label() string {
+	return "Position"
+}
+
+// Description for the Property
+func (position *SecurityRulePositionProperty) Description() string {
+	return "The position of the rule in its scope, starting at 0."
+}
+
+// Is the Property internal only
+func (position *SecurityRulePositionProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (position *SecurityRulePositionProperty) Copy() api_property.Property {
+	prop := &SecurityRulePositionProperty{}
+	prop.Set(position.Get())
+	return api_property.Property(prop)
+}
+
+// Property for listed rules
+type SecurityRuleRulesProperty struct {
+	api_property.StringSliceProperty
+}
+
+// Id for the Property
+func (rules *SecurityRuleRulesProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_RULES
+}
+
+// Label for the Property
+func (rules *SecurityRuleRulesProperty) Label() string {
+	return "Rules"
+}
+
+// Description for the Property
+func (rules *SecurityRuleRulesProperty) Description() string {
+	return "Authorization rules, as scope[position] followed by the rule."
+}
+
+// Is the Property internal only
+func (rules *SecurityRuleRulesProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (rules *SecurityRuleRulesProperty) Copy() api_property.Property {
+	prop := &SecurityRuleRulesProperty{}
+	prop.Set(rules.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the issues found by the rule lint operation
+type SecurityRuleIssuesProperty struct {
+	api_property.StringSliceProperty
+}
+
+// Id for the Property
+func (issues *SecurityRuleIssuesProperty) Id() string {
+	return OPERATION_PROPERTY_SECURITY_RULE_ISSUES
+}
+
+// Label for the Property
+func (issues *SecurityRuleIssuesProperty) Label() string {
+	return "Lint issues"
+}
+
+// Description for the Property
+func (issues *SecurityRuleIssuesProperty) Description() string {
+	return "Problems found in the authorization rules."
+}
+
+// Is the Property internal only
+func (issues *SecurityRuleIssuesProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (issues *SecurityRuleIssuesProperty) Copy() api_property.Property {
+	prop := &SecurityRuleIssuesProperty{}
+	prop.Set(issues.Get())
+	return api_property.Property(prop)
+}
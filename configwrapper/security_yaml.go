@@ -2,9 +2,10 @@ package configwrapper
 
 import (
 	"errors"
+	"os/user"
 
 	log "github.com/Sirupsen/logrus"
-	// "gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v2"
 
 	api_operation "github.com/wunderkraut/radi-api/operation"
 	api_config "github.com/wunderkraut/radi-api/operation/config"
@@ -20,9 +21,20 @@ func New_SecurityConfigWrapperYml(wrapper api_config.ConfigWrapper) *SecurityCon
 
 // A SecurityConfirWrapper that reads config as yml
 type SecurityConfigWrapperYml struct {
-	authHandler SecurityConfigWrapperAuthorizeYmlHandler
-	userHandler SecurityConfigWrapperUserYmlHandler
-	wrapper     api_config.ConfigWrapper
+	authHandler  SecurityConfigWrapperAuthorizeYmlHandler
+	userHandler  SecurityConfigWrapperUserYmlHandler
+	rolesHandler SecurityConfigWrapperRolesYmlHandler
+	wrapper      api_config.ConfigWrapper
+
+	userSource SecurityUserSource // optional source for the acting user, @see security_yaml_roles.go
+	osUser     *user.User         // the OS user for actor uid and gid, the process user if nil
+	conditions ConditionContext   // used to evaluate rule When conditions
+
+	authSources api_config.ConfigScopedValues // the raw authorize yml for each scope, as it was loaded
+	authDirty   []string                      // authorize scopes changed since the last save
+	authIssues  []SettingLintIssue            // invalid authorize config found when loading
+
+	schema *SettingsSchema // the settings schema, used to redact secret setting values
 }
 
 // Convert this into a SecurityConfigWrapper
@@ -32,18 +44,64 @@ func (security *SecurityConfigWrapperYml) SecurityConfigWrapper() SecurityConfig
 }
 
 // Safe lazy initializer
+//
+// Invalid authorize config is logged and kept as issues, which the
+// security.rule.lint operation reports, @see AuthorizeIssues.
 func (security *SecurityConfigWrapperYml) safe() {
 	if security.authHandler.Empty() {
 		security.LoadAuthorize() // @see security_yaml_authorization.go
 		security.LoadUser()      // @see security_yaml_user.go
+		security.LoadRoles()     // @see security_yaml_roles.go
 	}
 }
 
-// Save the current values to the wrapper
+// Save the changed authorization scopes to the wrapper
+//
+// Each changed scope is written from its original source, with only
+// the Settings and Rules replaced, so that other top level keys are
+// kept.  Rules are written as they were loaded, without defaults.
 func (security *SecurityConfigWrapperYml) Save() error {
-	err := errors.New("SecurityConfigWrapper.Save() not yet writtent")
-	log.WithError(err).Error("Could not save security config")
-	return err
+	if len(security.authDirty) == 0 {
+		return nil
+	}
+
+	scopedValues := api_config.ConfigScopedValues{}
+	for _, scope := range security.authDirty {
+		definition, found := security.authHandler.Get(scope)
+		if !found {
+			continue
+		}
+
+		document := yaml.MapSlice{}
+		if scopedSource, found := security.authSources.Get(scope); found {
+			if err := yaml.Unmarshal(scopedSource, &document); err != nil {
+				err = errors.New("Could not parse existing authorize config in scope " + scope + ": " + err.Error())
+				log.WithError(err).Error("Could not save security config")
+				return err
+			}
+		}
+		document = ymlTool_SetMapSliceValue(document, "Settings", definition.Settings)
+		document = ymlTool_SetMapSliceValue(document, "Rules", definition.SourceRules)
+
+		scopedSource, err := yaml.Marshal(document)
+		if err != nil {
+			log.WithError(err).Error("Could not save security config")
+			return err
+		}
+		scopedValues.Set(scope, api_config.ConfigScopedValue(scopedSource))
+	}
+
+	if err := security.wrapper.Set(CONFIG_KEY_SECURITY_AUTHORIZE, scopedValues); err != nil {
+		log.WithError(err).Error("Could not save security config")
+		return err
+	}
+
+	for _, scope := range scopedValues.Order() {
+		scopedSource, _ := scopedValues.Get(scope)
+		security.authSources.Set(scope, scopedSource)
+	}
+	security.authDirty = []string{}
+	return nil
 }
 
 func (security *SecurityConfigWrapperYml) AuthorizeRules() api_security.AuthorizeOperationRules {
@@ -51,10 +109,59 @@ func (security *SecurityConfigWrapperYml) AuthorizeRules() api_security.Authoriz
 	return security.authHandler.Rules()
 }
 
+// Set the context used to evaluate rule When conditions
+func (security *SecurityConfigWrapperYml) SetConditionContext(conditions ConditionContext) {
+	security.conditions = conditions
+}
+
+// Get the context used to evaluate rule When conditions, which defaults to the running process
+func (security *SecurityConfigWrapperYml) ConditionContext() ConditionContext {
+	if security.conditions == nil {
+		security.conditions = New_StandardConditionContext("", "", nil).ConditionContext()
+	}
+	return security.conditions
+}
+
 // Get an ordered list of rules (SecurityConfigWrapper interface)
 func (security *SecurityConfigWrapperYml) AuthorizeOperation(op api_operation.Operation) api_security.RuleResult {
 	//log.WithFields(log.Fields{"op": op.Id()}).Info("Authorizing operation")
-	return security.authHandler.Rules().AuthorizeOperation(op)
+	security.safe()
+	return security.authHandler.Authorize(op, security.Actor(op), security.ConditionContext(), nil)
+}
+
+// Authorize an operation, and explain how the decision was made (SecurityConfigWrapper interface)
+func (security *SecurityConfigWrapperYml) ExplainOperation(op api_operation.Operation) (api_security.RuleResult, SecurityAuthorizeTrace) {
+	security.safe()
+	trace := SecurityAuthorizeTrace{}
+	result := security.authHandler.Authorize(op, security.Actor(op), security.ConditionContext(), &trace)
+	security.redactTrace(op, &trace)
+	return result, trace
+}
+
+// Redact the secret property values in a trace
+func (security *SecurityConfigWrapperYml) redactTrace(op api_operation.Operation, trace *SecurityAuthorizeTrace) {
+	props := op.Properties()
+	for ruleIndex := range trace.Rules {
+		ruleTrace := &trace.Rules[ruleIndex]
+		for propIndex := range ruleTrace.Properties {
+			propTrace := &ruleTrace.Properties[propIndex]
+			if propTrace.Found && securityPropertySecret(props, propTrace.Property, security.settingsSchema()) {
+				propTrace.Value = SECURITY_AUDIT_REDACTED
+			}
+		}
+	}
+}
+
+// Get the settings schema, loading it when first used
+func (security *SecurityConfigWrapperYml) settingsSchema() *SettingsSchema {
+	if security.schema == nil {
+		schema, err := LoadSettingsSchema(security.wrapper)
+		if err != nil {
+			log.WithError(err).Debug("No settings schema, so only secret setting patterns are redacted")
+		}
+		security.schema = &schema
+	}
+	return security.schema
 }
 
 // Get an ordered list of rules (SecurityConfigWrapper interface)
@@ -1,14 +1,17 @@
 package configwrapper
 
 import (
-	// "errors"
+	"errors"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 
 	api_operation "github.com/wunderkraut/radi-api/operation"
-	// api_config "github.com/wunderkraut/radi-api/operation/config"
+	api_config "github.com/wunderkraut/radi-api/operation/config"
 	api_security "github.com/wunderkraut/radi-api/operation/security"
 )
 
@@ -19,18 +22,41 @@ import (
 // Retrieve values by parsing bytes from the wrapper
 func (security *SecurityConfigWrapperYml) LoadAuthorize() error {
 	security.authHandler = SecurityConfigWrapperAuthorizeYmlHandler{}
+	security.authSources = api_config.ConfigScopedValues{}
+	security.authDirty = []string{}
+	security.authIssues = []SettingLintIssue{}
 
 	if sources, err := security.wrapper.Get(CONFIG_KEY_SECURITY_AUTHORIZE); err == nil {
+		security.authSources = sources // keep the raw source so that saves can keep other keys
 		for _, scope := range sources.Order() {
 			scopedSource, _ := sources.Get(scope)
 			scopedValues := SecurityConfigWrapperAuthorizeYmlDefinition{}
 			if err := yaml.Unmarshal(scopedSource, &scopedValues); err == nil {
 				security.authHandler.Add(scope, scopedValues)
+				definition, _ := security.authHandler.Get(scope)
+				security.authIssues = append(security.authIssues, definition.compile(scope, strings.Split(string(scopedSource), "\n"))...)
 			} else {
-				log.WithError(err).WithFields(log.Fields{"scope": scope}).Error("SecurityConfigWrapper Couldn't unmarshall auth yml scope")
+				issue := SettingLintIssue{Severity: SETTING_LINT_ERROR, Scope: scope, File: settingScopeFileName(CONFIG_KEY_SECURITY_AUTHORIZE, scope), Message: err.Error()}
+				if match := settingLintYamlLine.FindStringSubmatch(err.Error()); match != nil {
+					issue.Line, _ = strconv.Atoi(match[1])
+				}
+				security.authIssues = append(security.authIssues, issue)
 			}
 			//log.WithFields(log.Fields{"values": scopedValues, "authHandler": security.authHandler, "scope": scope}).Info("Security:Config->Load()")
 		}
+
+		messages := []string{}
+		for _, issue := range security.authIssues {
+			if issue.Severity != SETTING_LINT_ERROR {
+				log.Warn("Authorization rules: " + issue.String())
+				continue
+			}
+			log.Error("Authorization rules: " + issue.String())
+			messages = append(messages, issue.String())
+		}
+		if len(messages) > 0 {
+			return errors.New("Invalid authorization rules: " + strings.Join(messages, "; "))
+		}
 		return nil
 	} else {
 		log.WithError(err).Error("Error loading config for " + CONFIG_KEY_SECURITY_AUTHORIZE)
@@ -68,6 +94,7 @@ func (handler *SecurityConfigWrapperAuthorizeYmlHandler) Add(id string, definiti
 	if _, found := handler.definitions[id]; !found {
 		handler.order = append(handler.order, id)
 	}
+	definition.scope = id
 	handler.definitions[id] = &definition
 }
 
@@ -86,117 +113,443 @@ func (handler *SecurityConfigWrapperAuthorizeYmlHandler) Order() []string {
 
 // Get an ordered list of rules (SecurityConfigWrapper interface)
 func (handler *SecurityConfigWrapperAuthorizeYmlHandler) Rules() api_security.AuthorizeOperationRules {
-	handler.safe()
-
 	rules := api_security.SimpleAuthorizeOperationRules{}
 
-	for _, id := range handler.order {
-		definition, _ := handler.Get(id)
+	for _, ymlRule := range handler.orderedRules() {
+		rule, err := ymlRule.Rule()
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"rule": ymlRule.Id, "rule-auth": ymlRule.Authorize}).Warn("ymlRule is invalid, so it denies the operations it could match.")
+		}
+		//log.WithFields(log.Fields{"rule": ymlRule.Id, "rule-auth": ymlRule.Authorize, "*rule": &ymlRule}).Info("Collected ymlRule")
+		rules.Set(ymlRule.ScopedId(), rule)
+	}
+
+	return api_security.AuthorizeOperationRules(&rules)
+}
+
+// Get the rules of all scopes in order, with defaults applied
+//
+// Rules are namespaced by their scope, so rules with the same Id in
+// different scopes are separate rules.  The rules of the project
+// scopes come first, so that other scopes cannot outrank them.
+func (handler *SecurityConfigWrapperAuthorizeYmlHandler) orderedRules() []*SecurityConfigWrapperAuthorizeYmlRule {
+	handler.safe()
+
+	projectRules := []*SecurityConfigWrapperAuthorizeYmlRule{}
+	otherRules := []*SecurityConfigWrapperAuthorizeYmlRule{}
+	for _, scope := range handler.order {
+		definition, _ := handler.Get(scope)
 
 		for _, ymlRule := range definition.Rules() {
-			if rule, err := ymlRule.Rule(); err == nil {
-				//log.WithFields(log.Fields{"rule": ymlRule.Id, "rule-auth": ymlRule.Authorize, "*rule": &ymlRule}).Info("Collected ymlRule")
-				rules.Set(ymlRule.Id, rule)
+			ymlRule.scope = scope
+			if securityProjectScope(scope) {
+				projectRules = append(projectRules, ymlRule)
 			} else {
-				log.WithError(err).WithFields(log.Fields{"rule": ymlRule.Id, "rule-auth": ymlRule.Authorize, "*rule": &rule}).Info("ymlRule could not create a valid rule.")
+				otherRules = append(otherRules, ymlRule)
+			}
+		}
+	}
+	return append(projectRules, otherRules...)
+}
+
+// Authorize an operation, recording each rule evaluation in a trace if one is passed
+//
+// Rules are evaluated in order, and combined using the combining
+// algorithm from the settings (@see security_combine.go).  If no rule
+// decides, the result neither allows nor denies.
+func (handler *SecurityConfigWrapperAuthorizeYmlHandler) Authorize(op api_operation.Operation, actor SecurityActor, conditions ConditionContext, trace *SecurityAuthorizeTrace) api_security.RuleResult {
+	combine := handler.Combine()
+	if trace != nil {
+		trace.Operation = op.Id()
+		trace.Actor = actor
+		trace.Combine = combine
+		trace.Rules = []SecurityRuleTrace{}
+		trace.Decision = SECURITY_TRACE_DECISION_NONE
+	}
+
+	rules := handler.orderedRules()
+	if combine == SECURITY_COMBINE_PRIORITY {
+		// priorities only order rules within the project scopes, or within the other scopes
+		sort.SliceStable(rules, func(i, j int) bool {
+			if iProject, jProject := securityProjectScope(rules[i].scope), securityProjectScope(rules[j].scope); iProject != jProject {
+				return iProject
 			}
+			return rules[i].Priority > rules[j].Priority
+		})
+	}
+
+	var decidingRule *SecurityConfigWrapperAuthorizeYmlRule
+	decision := 0
+	for _, ymlRule := range rules {
+		var ruleTrace *SecurityRuleTrace
+		if trace != nil {
+			trace.Rules = append(trace.Rules, SecurityRuleTrace{Scope: ymlRule.scope, Rule: ymlRule.Id, Operation: ymlRule.Operation, Priority: ymlRule.Priority})
+			ruleTrace = &trace.Rules[len(trace.Rules)-1]
+		}
 
+		authValue := ymlRule.evaluate(op, actor, conditions, ruleTrace)
+		if authValue == 0 {
+			continue
+		}
+		if decidingRule == nil || securityCombineOverrides(combine, authValue) {
+			decidingRule, decision = ymlRule, authValue
+		}
+		if securityCombineFinal(combine, authValue) {
+			break
 		}
 	}
 
-	return api_security.AuthorizeOperationRules(&rules)
+	if decidingRule == nil {
+		return api_security.New_SimpleRuleResult("", "No authorization rule applied", 0).RuleResult()
+	}
+	if trace != nil {
+		trace.Decision = securityTraceOutcome(decision)
+		trace.DecidingRule = decidingRule.ScopedId()
+		trace.Message = decidingRule.message()
+	}
+	return decidingRule.result(decision)
+}
+
+// Get the combining algorithm, from the highest priority project scope which sets one
+//
+// Other scopes cannot choose the algorithm, so that a user scope cannot
+// make its own rules override the project rules.
+func (handler *SecurityConfigWrapperAuthorizeYmlHandler) Combine() string {
+	handler.safe()
+	for _, scope := range handler.order {
+		definition, _ := handler.Get(scope)
+		if combine := definition.Settings.Combine; combine != "" {
+			if !securityProjectScope(scope) {
+				log.WithFields(log.Fields{"scope": scope, "combine": combine}).Debug("Ignoring the authorization combining algorithm outside of the " + SECURITY_PROJECT_SCOPE + " scope")
+				continue
+			}
+			if securityCombineValid(combine) {
+				return combine
+			}
+			log.WithFields(log.Fields{"scope": scope, "combine": combine}).Error("Unknown authorization combining algorithm, using " + SECURITY_COMBINE_DENY_OVERRIDES)
+			return SECURITY_COMBINE_DENY_OVERRIDES
+		}
+	}
+	return SECURITY_COMBINE_FIRST_APPLICABLE
 }
 
 // Yml Rule set container
 type SecurityConfigWrapperAuthorizeYmlDefinition struct {
 	Settings    SecurityConfigWrapperAuthorizeYmlSettings `yaml:"Settings"`
 	SourceRules []*SecurityConfigWrapperAuthorizeYmlRule  `yaml:"Rules"`
+
+	scope string                                   // the scope the definition was loaded from
+	rules []*SecurityConfigWrapperAuthorizeYmlRule // compiled rules, or nil if the source rules changed
 }
 
-// Get an ordered list of rules
+// Get an ordered list of compiled rules, with the defaults applied
+//
+// The rules are copies, so that the source rules keep only what was
+// written in config, and can be saved without the defaults.  They are
+// compiled when loaded, or when first used after the source rules
+// change.
 func (definition *SecurityConfigWrapperAuthorizeYmlDefinition) Rules() []*SecurityConfigWrapperAuthorizeYmlRule {
-	definition.applyDefaults()
-	return definition.SourceRules
+	if definition.rules == nil {
+		definition.compile(definition.scope, nil)
+	}
+	return definition.rules
 }
 
-// Apply defaults to all the rules
-func (definition *SecurityConfigWrapperAuthorizeYmlDefinition) applyDefaults() {
-	for index, rule := range definition.SourceRules {
-		if rule.Message == "" {
-			rule.Message = definition.Settings.DefaultMessage
-		}
-		if rule.Authorize == "" {
-			rule.Authorize = definition.Settings.DefaultAuthorize
-		}
-		if rule.Aggregate == "" {
-			rule.Aggregate = definition.Settings.DefaultAggregate
+// Compile the source rules of a scope, returning any issues found
+//
+// Invalid rules are kept, and deny the operations that they could
+// match, so that a broken rule fails closed.  The scope source lines are
+// used to report the line of each issue, and can be nil.
+func (definition *SecurityConfigWrapperAuthorizeYmlDefinition) compile(scope string, lines []string) []SettingLintIssue {
+	issues := []SettingLintIssue{}
+	file := settingScopeFileName(CONFIG_KEY_SECURITY_AUTHORIZE, scope)
+
+	if combine := definition.Settings.Combine; combine != "" && !securityCombineValid(combine) {
+		issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_ERROR, Scope: scope, File: file, Line: ymlTool_FindLine(lines, "Combine:"), Key: "Settings.Combine", Message: "unknown combining algorithm " + strconv.Quote(combine)})
+	}
+	if definition.Settings.Combine != "" && !securityProjectScope(scope) {
+		issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_WARNING, Scope: scope, File: file, Line: ymlTool_FindLine(lines, "Combine:"), Key: "Settings.Combine", Message: "only the " + SECURITY_PROJECT_SCOPE + " scope can set the combining algorithm, so it is ignored"})
+	}
+
+	definition.scope = scope
+	definition.rules = []*SecurityConfigWrapperAuthorizeYmlRule{}
+	for index, sourceRule := range definition.SourceRules {
+		rule := definition.applyDefaults(*sourceRule)
+		rule.scope = scope
+		if err := rule.compile(); err != nil {
+			start, _ := ymlTool_FindListItemLines(lines, "Rules:", index)
+			issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_ERROR, Scope: scope, File: file, Line: start + 1, Key: "Rules[" + strconv.Itoa(index) + "] " + rule.Id, Message: err.Error()})
 		}
+		definition.rules = append(definition.rules, &rule)
+	}
+	return issues
+}
 
-		definition.SourceRules[index] = rule
+// Apply defaults to a copy of a rule
+func (definition *SecurityConfigWrapperAuthorizeYmlDefinition) applyDefaults(rule SecurityConfigWrapperAuthorizeYmlRule) SecurityConfigWrapperAuthorizeYmlRule {
+	if rule.Message == "" {
+		rule.Message = definition.Settings.DefaultMessage
 	}
+	if rule.Authorize == "" {
+		rule.Authorize = definition.Settings.DefaultAuthorize
+	}
+	if rule.Aggregate == "" {
+		rule.Aggregate = definition.Settings.DefaultAggregate
+	}
+	return rule
 }
 
 // Yml Rule container
 type SecurityConfigWrapperAuthorizeYmlSettings struct {
-	DefaultAuthorize string `yaml:"Authorize"`
-	DefaultAggregate string `yaml:"Aggregate"`
-	DefaultMessage   string `yaml:"Message"`
+	DefaultAuthorize string `yaml:"Authorize,omitempty"`
+	DefaultAggregate string `yaml:"Aggregate,omitempty"`
+	DefaultMessage   string `yaml:"Message,omitempty"`
+	Combine          string `yaml:"Combine,omitempty"` // the rule combining algorithm, @see security_combine.go
 }
 
 // Yml Rule container
 type SecurityConfigWrapperAuthorizeYmlRule struct {
 	Id         string              `yaml:"Id"`
-	Message    string              `yaml:"Message"`
+	Message    string              `yaml:"Message,omitempty"`
 	Operation  string              `yaml:"Operation"`
-	Authorize  string              `yaml:"Authorize"`
-	Aggregate  string              `yaml:"Aggregate"`
-	Properties map[string][]string `yaml:"Property"`
+	Authorize  string              `yaml:"Authorize,omitempty"`
+	Aggregate  string              `yaml:"Aggregate,omitempty"`
+	Properties map[string][]string `yaml:"Property,omitempty"`
+	Users      []string            `yaml:"User,omitempty"`
+	Groups     []string            `yaml:"Group,omitempty"`
+	Roles      []string            `yaml:"Role,omitempty"`
+	Actor      *SecurityActorMatch `yaml:"Actor,omitempty"`
+	Priority   int                 `yaml:"Priority,omitempty"` // higher priority rules are evaluated first by the priority algorithm
+	When       *Yml_Condition      `yaml:"When,omitempty"`     // a context condition, @see condition.go
+
+	scope string // the scope the rule was loaded from, set on copies with defaults applied
+
+	compiled  bool                      // has the rule been compiled
+	err       error                     // why the rule is invalid, if it is
+	operation *regexp.Regexp            // the compiled Operation match, nil for "*"
+	regexes   map[string]*regexp.Regexp // compiled regex: property matches
+}
+
+// Compile the rule, validating it and compiling its regular expressions
+func (ymlRule *SecurityConfigWrapperAuthorizeYmlRule) compile() error {
+	ymlRule.compiled = true
+	ymlRule.operation = nil
+	ymlRule.regexes = map[string]*regexp.Regexp{}
+	ymlRule.err = func() error {
+		if ymlRule.Id == "" {
+			return errors.New("rule has no Id")
+		}
+		if ymlRule.Operation == "" {
+			return errors.New("rule has no Operation match")
+		}
+		if ymlRule.Operation != "*" {
+			compiled, err := regexp.Compile(ymlRule.Operation)
+			if err != nil {
+				return errors.New("invalid Operation match: " + err.Error())
+			}
+			ymlRule.operation = compiled
+		}
+		if err := ymlRule.When.Validate(); err != nil {
+			return errors.New("invalid When condition: " + err.Error())
+		}
+		if authStringToInt(ymlRule.Authorize, false) == 0 {
+			return errors.New("unknown Authorize value " + strconv.Quote(ymlRule.Authorize) + ", expected allow or deny")
+		}
+		for propId, matches := range ymlRule.Properties {
+			for _, match := range matches {
+				if err := authCompileMatch(match, ymlRule.regexes); err != nil {
+					return errors.New("invalid Property match " + strconv.Quote(match) + " for " + propId + ": " + err.Error())
+				}
+			}
+		}
+		return nil
+	}()
+	return ymlRule.err
+}
+
+// Get the rule id, namespaced by the scope it was loaded from
+func (ymlRule *SecurityConfigWrapperAuthorizeYmlRule) ScopedId() string {
+	if ymlRule.scope == "" {
+		return ymlRule.Id
+	}
+	return ymlRule.scope + ":" + ymlRule.Id
 }
 
 // Conver this YmlRule to an api_security Rule
 func (ymlRule *SecurityConfigWrapperAuthorizeYmlRule) Rule() (api_security.AuthorizeOperationRule, error) {
-	return api_security.AuthorizeOperationRule(ymlRule), nil
+	if !ymlRule.compiled {
+		ymlRule.compile()
+	}
+	return api_security.AuthorizeOperationRule(ymlRule), ymlRule.err
 }
 
 // Conver this YmlRule to an api_security Rule
 func (ymlRule *SecurityConfigWrapperAuthorizeYmlRule) AuthorizeOperation(op api_operation.Operation) api_security.RuleResult {
+	return ymlRule.result(ymlRule.evaluate(op, New_SecurityActor(securityOperationUser(op)), nil, nil))
+}
+
+// Does the rule apply to an actor, returning a reason if not
+//
+// A rule with User, Group or Role lists needs the actor to match at
+// least one of them, and a rule with an Actor block needs the actor to
+// match all of its conditions.
+func (ymlRule *SecurityConfigWrapperAuthorizeYmlRule) appliesTo(actor SecurityActor) (bool, string) {
+	if len(ymlRule.Users) > 0 || len(ymlRule.Groups) > 0 || len(ymlRule.Roles) > 0 {
+		if !(securityListsIntersect(ymlRule.Users, []string{actor.Id}) ||
+			securityListsIntersect(ymlRule.Groups, actor.Groups) ||
+			securityListsIntersect(ymlRule.Roles, actor.Roles)) {
+			return false, "rule does not apply to user " + actor.Id
+		}
+	}
+	if matched, reason := ymlRule.Actor.Matches(actor); !matched {
+		return false, "rule does not apply: " + reason
+	}
+	return true, ""
+}
+
+// Evaluate the rule for an operation, returning the auth value, and recording each step in a trace if one is passed
+//
+// When conditions are evaluated in the passed context, or in the
+// context of the running process if it is nil.  A rule which failed
+// to compile denies, unless its operation match compiled and does not
+// match the operation, and so does a rule which cannot match a property.
+func (ymlRule *SecurityConfigWrapperAuthorizeYmlRule) evaluate(op api_operation.Operation, actor SecurityActor, conditions ConditionContext, trace *SecurityRuleTrace) int {
 	//log.WithFields(log.Fields{"rule": ymlRule, "op": op.Id()}).Info("Checking Rule")
+	authValue := 0
+	if trace != nil {
+		defer func() {
+			trace.Outcome = securityTraceOutcome(authValue)
+		}()
+	}
 
-	if len(ymlRule.Operation) == 0 {
-		log.WithFields(log.Fields{"rule": ymlRule}).Error("Rule had no operation match definition")
-		return ymlRule.result(0)
+	if !ymlRule.compiled {
+		ymlRule.compile()
+	}
+	if ymlRule.err != nil {
+		if ymlRule.operation != nil && !ymlRule.operation.MatchString(op.Id()) {
+			if trace != nil {
+				trace.Reason = "operation id did not match"
+			}
+			return authValue
+		}
+		authValue = authStringToInt("deny", false)
+		if trace != nil {
+			trace.Reason = "rule error: " + ymlRule.err.Error() + ", so the rule denies"
+		}
+		return authValue
 	}
 
 	// match operation id
-	if ymlRule.Operation != "*" {
-		if match, _ := regexp.MatchString(ymlRule.Operation, op.Id()); !match {
+	if ymlRule.operation != nil {
+		if !ymlRule.operation.MatchString(op.Id()) {
 			//log.WithFields(log.Fields{"match": ymlRule.Operation, "op": op.Id(), "rule": ymlRule}).Info("Rule id did not match")
-			return ymlRule.result(0)
+			if trace != nil {
+				trace.Reason = "operation id did not match"
+			}
+			return authValue
+		}
+	}
+	if trace != nil {
+		trace.OperationMatched = true
+	}
+
+	// match the acting user
+	if applies, reason := ymlRule.appliesTo(actor); !applies {
+		if trace != nil {
+			trace.Reason = reason
+		}
+		return authValue
+	}
+	if trace != nil {
+		trace.ActorMatched = true
+	}
+
+	// match the context
+	if ymlRule.When != nil {
+		if conditions == nil {
+			conditions = New_StandardConditionContext("", "", nil).ConditionContext()
+		}
+		if !ymlRule.When.Holds(conditions) {
+			if trace != nil {
+				trace.Reason = "rule When condition does not hold"
+			}
+			return authValue
 		}
 	}
 
-	// match properties
+	// match properties, in a stable order
+	propIds := []string{}
+	for propId := range ymlRule.Properties {
+		propIds = append(propIds, propId)
+	}
+	sort.Strings(propIds)
+
 	opProps := op.Properties()
-	for propId, propValues := range ymlRule.Properties {
+	for _, propId := range propIds {
+		propValues := ymlRule.Properties[propId]
 		if prop, found := opProps.Get(propId); found {
-			if authMatchProperty(prop, propValues) {
-				return ymlRule.result(authStringToInt(ymlRule.Authorize, false))
+			matched, err := authMatchProperty(prop, propValues, ymlRule.regexes)
+			if trace != nil {
+				propTrace := SecurityPropertyTrace{Property: propId, Expected: propValues, Value: securityPropertyValue(prop.Get()), Found: true, Matched: matched}
+				if err != nil {
+					propTrace.Error = err.Error()
+				}
+				trace.Properties = append(trace.Properties, propTrace)
+			}
+			if err != nil {
+				// a broken match fails closed, as a rule which fails to compile does
+				log.WithError(err).WithFields(log.Fields{"rule": ymlRule.ScopedId(), "op": op.Id()}).Error("Authorization rule could not match a property, so the rule denies")
+				authValue = authStringToInt("deny", false)
+				if trace != nil {
+					trace.Reason = "rule error: " + err.Error() + ", so the rule denies"
+				}
+				return authValue
+			}
+			if matched {
+				authValue = authStringToInt(ymlRule.Authorize, false)
+				if trace != nil {
+					trace.Reason = "property " + propId + " matched"
+				}
 			} else {
 				//log.WithFields(log.Fields{"rule": ymlRule, "prop": prop}).Info("failed to match rule")
-				return ymlRule.result(authStringToInt(ymlRule.Authorize, true))
+				authValue = authStringToInt(ymlRule.Authorize, true)
+				if trace != nil {
+					trace.Reason = "property " + propId + " did not match, so the rule authorization is inverted"
+				}
+			}
+			return authValue
+		} else {
+			if trace != nil {
+				trace.Properties = append(trace.Properties, SecurityPropertyTrace{Property: propId, Expected: propValues})
+			}
+			if ymlRule.Aggregate == "AND" {
+				//log.WithFields(log.Fields{"rule": ymlRule, "prop": propId}).Info("failed to match rule as property was not found in the rule")
+				authValue = authStringToInt(ymlRule.Authorize, true)
+				if trace != nil {
+					trace.Reason = "property " + propId + " was not found and the rule aggregates with AND, so the rule authorization is inverted"
+				}
+				return authValue
 			}
-		} else if ymlRule.Aggregate == "AND" {
-			//log.WithFields(log.Fields{"rule": ymlRule, "prop": propId}).Info("failed to match rule as property was not found in the rule")
-			return ymlRule.result(authStringToInt(ymlRule.Authorize, true))
 		}
-
 	}
 
 	log.WithFields(log.Fields{"auth": ymlRule.Authorize, "rule": ymlRule.Id, "op": op.Id()}).Debug("SecurityConfigWrapperAuthorizeYmlRule.AuthorizeOperation() : Rule Matched. Applying rule auth")
-	return ymlRule.result(authStringToInt(ymlRule.Authorize, false))
+	authValue = authStringToInt(ymlRule.Authorize, false)
+	if trace != nil {
+		trace.Reason = "rule matched"
+	}
+	return authValue
 }
 
 // Convert this rule into a RuleResult depending on value
 func (ymlRule *SecurityConfigWrapperAuthorizeYmlRule) result(authValue int) api_security.RuleResult {
-	return api_security.New_SimpleRuleResult(ymlRule.Id, ymlRule.Message, authValue).RuleResult()
+	return api_security.New_SimpleRuleResult(ymlRule.ScopedId(), ymlRule.message(), authValue).RuleResult()
+}
+
+// The rule message, which explains the denial if the rule is invalid
+func (ymlRule *SecurityConfigWrapperAuthorizeYmlRule) message() string {
+	if ymlRule.err != nil {
+		return "Authorization rule " + ymlRule.ScopedId() + " is invalid: " + ymlRule.err.Error()
+	}
+	return ymlRule.Message
 }
@@ -0,0 +1,297 @@
+package configwrapper
+
+import (
+	"os/user"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	api_operation "github.com/wunderkraut/radi-api/operation"
+	api_security "github.com/wunderkraut/radi-api/operation/security"
+)
+
+/**
+ * Roles, which are mapped to users and groups in roles.yml
+ * in the project scope, so that a project can give roles to
+ * its team without each user configuring them.  Mappings in
+ * other scopes are ignored, so that users cannot give
+ * themselves roles.
+ *
+ *   Groups:
+ *     ops: [jane]
+ *   Roles:
+ *     maintainer:
+ *       Users: [jane]
+ *       Groups: [ops]
+ */
+
+const (
+	// The only config scope that role mappings, and user groups and roles, are read from
+	SECURITY_ROLES_SCOPE = "project"
+)
+
+// Retrieve role mappings by parsing bytes from the project scope of the wrapper
+func (security *SecurityConfigWrapperYml) LoadRoles() error {
+	security.rolesHandler = SecurityConfigWrapperRolesYmlHandler{}
+
+	sources, err := security.wrapper.Get(CONFIG_KEY_SECURITY_ROLES)
+	if err != nil {
+		log.WithFields(log.Fields{"config-key": CONFIG_KEY_SECURITY_ROLES}).WithError(err).Debug("Config wrapper could not load any role mappings")
+		return err
+	}
+	for _, scope := range sources.Order() {
+		if scope != SECURITY_ROLES_SCOPE {
+			log.WithFields(log.Fields{"scope": scope}).Warn("Ignoring role mappings outside of the " + SECURITY_ROLES_SCOPE + " scope")
+		}
+	}
+
+	scopedSource, found := sources.Get(SECURITY_ROLES_SCOPE)
+	if !found {
+		return nil
+	}
+	scopedValues := SecurityConfigWrapperRolesYmlDefinition{}
+	if err := yaml.Unmarshal(scopedSource, &scopedValues); err != nil {
+		log.WithError(err).WithFields(log.Fields{"scope": SECURITY_ROLES_SCOPE}).Error("SecurityConfigWrapperYml Couldn't unmarshall roles yml scope")
+		return err
+	}
+	security.rolesHandler.Add(SECURITY_ROLES_SCOPE, scopedValues)
+	return nil
+}
+
+// Something which can give the current user, such as a handler user source with fallbacks
+type SecurityUserSource interface {
+	CurrentUser() api_security.SecurityUser
+}
+
+// A security wrapper which knows the user acting in the running process, such as a session user
+type SecurityActingUserWrapper interface {
+	ActingUser() api_security.SecurityUser
+}
+
+// Get the acting user of a security wrapper, or its config user if it does not know one
+func securityActingUser(wrapper SecurityConfigWrapper) api_security.SecurityUser {
+	if actingWrapper, ok := wrapper.(SecurityActingUserWrapper); ok {
+		return actingWrapper.ActingUser()
+	}
+	return wrapper.CurrentUser()
+}
+
+// Use a source for the current user when authorizing, instead of only the user config
+func (security *SecurityConfigWrapperYml) SetUserSource(source SecurityUserSource) {
+	security.userSource = source
+}
+
+// Get the user acting in the running process, from the user source if there is one, or else the user config
+func (security *SecurityConfigWrapperYml) ActingUser() api_security.SecurityUser {
+	if security.userSource != nil {
+		if actingUser := security.userSource.CurrentUser(); actingUser != nil {
+			return actingUser
+		}
+	}
+	return security.CurrentUser()
+}
+
+// Use an OS user for the uid and gid of the actor, instead of the user running the process
+func (security *SecurityConfigWrapperYml) SetOSUser(osUser *user.User) {
+	security.osUser = osUser
+}
+
+// Get the acting user for authorization, with groups, all roles, and OS ids
+//
+// The user is the value of the operation user property if it has one,
+// or else the acting user, @see ActingUser.
+func (security *SecurityConfigWrapperYml) Actor(op api_operation.Operation) SecurityActor {
+	security.safe()
+
+	actingUser := securityOperationUser(op)
+	if actingUser == nil {
+		actingUser = security.ActingUser()
+	}
+	actor := New_SecurityActor(actingUser)
+	actor.Groups = securityAppendUnique(actor.Groups, security.rolesHandler.Groups(actor.Id)...)
+	actor.Roles = securityAppendUnique(actor.Roles, security.rolesHandler.Roles(actor.Id, actor.Groups)...)
+
+	if security.osUser == nil {
+		if current, err := user.Current(); err == nil {
+			security.osUser = current
+		} else {
+			log.WithError(err).Debug("Could not determine the OS user for authorization")
+		}
+	}
+	if security.osUser != nil {
+		actor.Uid = security.osUser.Uid
+		actor.Gids = []string{security.osUser.Gid}
+		if gids, err := security.osUser.GroupIds(); err == nil {
+			actor.Gids = securityAppendUnique(actor.Gids, gids...)
+		}
+	}
+	return actor
+}
+
+/**
+ * A handler for role mappings from config yml
+ */
+
+// Group memberships and role mappings
+type SecurityConfigWrapperRolesYmlHandler struct {
+	groups map[string][]string
+	roles  map[string]SecurityConfigWrapperRoleYmlDefinition
+}
+
+// Add a definition, combining its mappings with those already added
+func (rolesHandler *SecurityConfigWrapperRolesYmlHandler) Add(scope string, def SecurityConfigWrapperRolesYmlDefinition) {
+	if rolesHandler.roles == nil {
+		rolesHandler.groups = map[string][]string{}
+		rolesHandler.roles = map[string]SecurityConfigWrapperRoleYmlDefinition{}
+	}
+	for group, users := range def.Groups {
+		rolesHandler.groups[group] = securityAppendUnique(rolesHandler.groups[group], users...)
+	}
+	for role, mapping := range def.Roles {
+		existing := rolesHandler.roles[role]
+		existing.Users = securityAppendUnique(existing.Users, mapping.Users...)
+		existing.Groups = securityAppendUnique(existing.Groups, mapping.Groups...)
+		rolesHandler.roles[role] = existing
+	}
+}
+
+// Get the groups that a user id is a member of
+func (rolesHandler *SecurityConfigWrapperRolesYmlHandler) Groups(userId string) []string {
+	groups := []string{}
+	for group, users := range rolesHandler.groups {
+		if securityListsIntersect(users, []string{userId}) {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// Get the roles mapped to a user id, or to any of a list of groups
+func (rolesHandler *SecurityConfigWrapperRolesYmlHandler) Roles(userId string, groups []string) []string {
+	roles := []string{}
+	for role, mapping := range rolesHandler.roles {
+		if securityListsIntersect(mapping.Users, []string{userId}) || securityListsIntersect(mapping.Groups, groups) {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// Roles definition from yml
+type SecurityConfigWrapperRolesYmlDefinition struct {
+	Groups map[string][]string                               `yaml:"Groups,omitempty"` // the users in each group
+	Roles  map[string]SecurityConfigWrapperRoleYmlDefinition `yaml:"Roles"`
+}
+
+// The users and groups given a single role
+type SecurityConfigWrapperRoleYmlDefinition struct {
+	Users  []string `yaml:"Users,omitempty"`
+	Groups []string `yaml:"Groups,omitempty"`
+}
+
+/**
+ * The acting user, as seen by authorization rules
+ */
+
+// Constructor for SecurityActor
+func New_SecurityActor(user api_security.SecurityUser) SecurityActor {
+	actor := SecurityActor{}
+	if user != nil {
+		actor.Id = user.Id()
+		actor.Label = user.Label()
+	}
+	if groupUser, ok := user.(SecurityGroupUser); ok {
+		actor.Groups = append([]string{}, groupUser.Groups()...)
+		actor.Roles = append([]string{}, groupUser.Roles()...)
+	}
+	return actor
+}
+
+// A user being authorized, with its groups and roles
+type SecurityActor struct {
+	Id     string
+	Label  string
+	Groups []string
+	Roles  []string
+	Uid    string   // the OS user id running radi
+	Gids   []string // the OS group ids of the OS user, primary first
+}
+
+// Match conditions on the acting user in a rule
+//
+// Each field that is set must match, where a field matches if any
+// of its values do.
+type SecurityActorMatch struct {
+	Id    []string `yaml:"Id,omitempty"`
+	Label []string `yaml:"Label,omitempty"`
+	Group []string `yaml:"Group,omitempty"`
+	Role  []string `yaml:"Role,omitempty"`
+	Uid   []string `yaml:"Uid,omitempty"`
+	Gid   []string `yaml:"Gid,omitempty"`
+}
+
+// Does an actor match, returning a reason if not
+func (match *SecurityActorMatch) Matches(actor SecurityActor) (bool, string) {
+	if match == nil {
+		return true, ""
+	}
+	if len(match.Id) > 0 && !securityListsIntersect(match.Id, []string{actor.Id}) {
+		return false, "user id " + actor.Id + " did not match"
+	}
+	if len(match.Label) > 0 && !securityListsIntersect(match.Label, []string{actor.Label}) {
+		return false, "user label " + actor.Label + " did not match"
+	}
+	if len(match.Group) > 0 && !securityListsIntersect(match.Group, actor.Groups) {
+		return false, "user groups did not match"
+	}
+	if len(match.Role) > 0 && !securityListsIntersect(match.Role, actor.Roles) {
+		return false, "user roles did not match"
+	}
+	if len(match.Uid) > 0 && !securityListsIntersect(match.Uid, []string{actor.Uid}) {
+		return false, "OS uid " + actor.Uid + " did not match"
+	}
+	if len(match.Gid) > 0 && !securityListsIntersect(match.Gid, actor.Gids) {
+		return false, "OS gids did not match"
+	}
+	return true, ""
+}
+
+// Describe the match conditions on a single line
+func (match SecurityActorMatch) String() string {
+	parts := []string{}
+	for _, field := range []struct {
+		name   string
+		values []string
+	}{{"id", match.Id}, {"label", match.Label}, {"group", match.Group}, {"role", match.Role}, {"uid", match.Uid}, {"gid", match.Gid}} {
+		if len(field.values) > 0 {
+			parts = append(parts, field.name+"="+strings.Join(field.values, ","))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Get the user from the user property of an operation, if it has one
+func securityOperationUser(op api_operation.Operation) api_security.SecurityUser {
+	if prop, found := op.Properties().Get(api_security.SECURITY_USER_PROPERTY_KEY); found {
+		if user, ok := prop.Get().(api_security.SecurityUser); ok && user != nil {
+			return user
+		}
+	}
+	return nil
+}
+
+// Do two lists share a value
+func securityListsIntersect(list []string, values []string) bool {
+	for _, value := range values {
+		for _, item := range list {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
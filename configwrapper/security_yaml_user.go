@@ -45,8 +45,16 @@ func (userHandler *SecurityConfigWrapperUserYmlHandler) safe() {
 }
 
 // Add a definition and merge it
+//
+// Groups and roles are only taken from the project scope, so that
+// users cannot give themselves groups or roles in their own user.yml.
 func (userHandler *SecurityConfigWrapperUserYmlHandler) Add(scope string, def SecurityConfigWrapperUserYmlDefinition) {
 	userHandler.safe()
+	if scope != SECURITY_ROLES_SCOPE && (len(def.UserGroups) > 0 || len(def.UserRoles) > 0) {
+		log.WithFields(log.Fields{"scope": scope}).Warn("Ignoring user Groups and Roles outside of the " + SECURITY_ROLES_SCOPE + " scope")
+		def.UserGroups = nil
+		def.UserRoles = nil
+	}
 	userHandler.def.Merge(def)
 }
 
@@ -56,10 +64,19 @@ func (userHandler *SecurityConfigWrapperUserYmlHandler) CurrentUser() api_securi
 	return userHandler.def.SecurityUser()
 }
 
+// A SecurityUser which belongs to groups, and has roles
+type SecurityGroupUser interface {
+	api_security.SecurityUser
+	Groups() []string
+	Roles() []string
+}
+
 // User definition from yml
 type SecurityConfigWrapperUserYmlDefinition struct {
-	UserId    string `yaml:"ID"`
-	UserLabel string `yaml:Label"`
+	UserId     string   `yaml:"ID"`
+	UserLabel  string   `yaml:"Label"`
+	UserGroups []string `yaml:"Groups,omitempty"`
+	UserRoles  []string `yaml:"Roles,omitempty"`
 }
 
 // Convert this into a SecurityUser
@@ -77,7 +94,19 @@ func (userDef *SecurityConfigWrapperUserYmlDefinition) Label() string {
 	return userDef.UserLabel
 }
 
+// interface:SecurityGroupUser : returns the groups that the user belongs to
+func (userDef *SecurityConfigWrapperUserYmlDefinition) Groups() []string {
+	return userDef.UserGroups
+}
+
+// interface:SecurityGroupUser : returns the roles given directly to the user
+func (userDef *SecurityConfigWrapperUserYmlDefinition) Roles() []string {
+	return userDef.UserRoles
+}
+
 // merge definitions
+//
+// Groups and roles are combined across definitions.
 func (userDef *SecurityConfigWrapperUserYmlDefinition) Merge(merge SecurityConfigWrapperUserYmlDefinition) {
 	if merge.UserId != "" {
 		userDef.UserId = merge.UserId
@@ -85,4 +114,23 @@ func (userDef *SecurityConfigWrapperUserYmlDefinition) Merge(merge SecurityConfi
 	if merge.UserLabel != "" {
 		userDef.UserLabel = merge.UserLabel
 	}
+	userDef.UserGroups = securityAppendUnique(userDef.UserGroups, merge.UserGroups...)
+	userDef.UserRoles = securityAppendUnique(userDef.UserRoles, merge.UserRoles...)
+}
+
+// Append values to a list, skipping values that it already has
+func securityAppendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
 }
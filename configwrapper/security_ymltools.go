@@ -1,12 +1,17 @@
 package configwrapper
 
 import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
-
-	log "github.com/Sirupsen/logrus"
+	"time"
 
 	api_property "github.com/wunderkraut/radi-api/property"
+
+	api_security "github.com/wunderkraut/radi-api/operation/security"
 )
 
 // Convert Authorize string to int, invert it if asked to
@@ -26,48 +31,231 @@ func authStringToInt(authValue string, negate bool) int {
 	}
 }
 
-// Does a property match some string values
-func authMatchProperty(prop api_property.Property, propStrings []string) bool {
-	switch prop.Type() {
-	case "bool":
-		if len(propStrings) == 0 {
+// Does a property match any of some rule match strings
+//
+// A match string is either an exact value, or an operator and operand:
+//
+//	regex:^dev-     the value matches a regular expression
+//	prefix:dev-     the value starts with a prefix
+//	glob:*.yml      the value matches a shell glob
+//	in:a,b,c        the value is one of a list
+//	not:<match>     the value does not match another match string
+//	<5 >5 <=5 >=5   the number or duration value compares to the operand
+//	between:1,5     the number or duration value is in an inclusive range
+//
+// Values of list properties match if any item matches (and a not: match
+// only if no item matches).  A SecurityUser value matches its id or label.
+// An error is returned for matches which cannot apply to the value type.
+//
+// Regular expressions are taken from a map of compiled regex: matches
+// when they are there (@see authCompileMatch), and compiled otherwise.
+func authMatchProperty(prop api_property.Property, propStrings []string, regexes map[string]*regexp.Regexp) (bool, error) {
+	values, err := authPropertyValues(prop.Get())
+	if err != nil {
+		return false, errors.New("Property " + prop.Id() + ": " + err.Error())
+	}
+
+	if len(propStrings) == 0 {
+		if _, isBool := prop.Get().(bool); isBool {
 			propStrings = []string{"true"}
 		}
-		propValue := prop.Get().(bool)
-		for _, stringValue := range propStrings {
-			value, _ := ymlTool_Convert_ToBool(stringValue)
-			if value == propValue {
-				return true
-			}
+	}
+
+	for _, match := range propStrings {
+		matched, err := authMatchValues(values, match, regexes)
+		if err != nil {
+			return false, errors.New("Property " + prop.Id() + " match " + strconv.Quote(match) + ": " + err.Error())
 		}
-	case "string":
-		propValue := prop.Get().(string)
-		for _, stringValue := range propStrings {
-			if stringValue == propValue {
-				return true
-			}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Convert a property value to a list of comparable values (bool, string, float64 or time.Duration)
+func authPropertyValues(value interface{}) ([]interface{}, error) {
+	switch typed := value.(type) {
+	case nil:
+		return []interface{}{}, nil
+	case bool, string, time.Duration:
+		return []interface{}{typed}, nil
+	case []byte:
+		return []interface{}{string(typed)}, nil
+	case []string:
+		values := []interface{}{}
+		for _, item := range typed {
+			values = append(values, item)
+		}
+		return values, nil
+	case int:
+		return []interface{}{float64(typed)}, nil
+	case int32:
+		return []interface{}{float64(typed)}, nil
+	case int64:
+		return []interface{}{float64(typed)}, nil
+	case float32:
+		return []interface{}{float64(typed)}, nil
+	case float64:
+		return []interface{}{typed}, nil
+	case api_security.SecurityUser:
+		if typed == nil {
+			return []interface{}{}, nil
 		}
-	case "stringslice":
-		propValues := prop.Get().([]string)
-		for _, stringValue := range propStrings {
-			for _, propValue := range propValues {
-				if stringValue == propValue {
-					return true
-				}
+		return []interface{}{typed.Id(), typed.Label()}, nil
+	default:
+		return nil, fmt.Errorf("values of type %T cannot be matched by authorization rules", value)
+	}
+}
+
+// Does any of a list of values match a match string
+func authMatchValues(values []interface{}, match string, regexes map[string]*regexp.Regexp) (bool, error) {
+	if strings.HasPrefix(match, "not:") {
+		matched, err := authMatchValues(values, strings.TrimPrefix(match, "not:"), regexes)
+		return !matched, err
+	}
+
+	for _, value := range values {
+		matched, err := authMatchValue(value, match, regexes)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// Does a single value match a match string
+func authMatchValue(value interface{}, match string, regexes map[string]*regexp.Regexp) (bool, error) {
+	switch {
+	case strings.HasPrefix(match, "regex:"):
+		expression, found := regexes[match]
+		if !found {
+			compiled, err := regexp.Compile(strings.TrimPrefix(match, "regex:"))
+			if err != nil {
+				return false, err
 			}
+			expression = compiled
 		}
-	case "int":
-		propValue := prop.Get().(int64)
-		for _, stringValue := range propStrings {
-			value, _ := ymlTool_Convert_ToInt(stringValue)
-			if value == propValue {
-				return true
+		return expression.MatchString(authValueString(value)), nil
+	case strings.HasPrefix(match, "prefix:"):
+		return strings.HasPrefix(authValueString(value), strings.TrimPrefix(match, "prefix:")), nil
+	case strings.HasPrefix(match, "glob:"):
+		return path.Match(strings.TrimPrefix(match, "glob:"), authValueString(value))
+	case strings.HasPrefix(match, "in:"):
+		for _, operand := range strings.Split(strings.TrimPrefix(match, "in:"), ",") {
+			if equal, err := authValueEquals(value, strings.TrimSpace(operand)); err != nil || equal {
+				return equal, err
 			}
 		}
+		return false, nil
+	case strings.HasPrefix(match, "between:"):
+		bounds := strings.Split(strings.TrimPrefix(match, "between:"), ",")
+		if len(bounds) != 2 {
+			return false, errors.New("between needs two operands, as between:low,high")
+		}
+		low, err := authValueCompare(value, strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return false, err
+		}
+		high, err := authValueCompare(value, strings.TrimSpace(bounds[1]))
+		if err != nil {
+			return false, err
+		}
+		return low >= 0 && high <= 0, nil
+	case strings.HasPrefix(match, "<="):
+		compared, err := authValueCompare(value, strings.TrimSpace(match[2:]))
+		return err == nil && compared <= 0, err
+	case strings.HasPrefix(match, ">="):
+		compared, err := authValueCompare(value, strings.TrimSpace(match[2:]))
+		return err == nil && compared >= 0, err
+	case strings.HasPrefix(match, "<"):
+		compared, err := authValueCompare(value, strings.TrimSpace(match[1:]))
+		return err == nil && compared < 0, err
+	case strings.HasPrefix(match, ">"):
+		compared, err := authValueCompare(value, strings.TrimSpace(match[1:]))
+		return err == nil && compared > 0, err
+	default:
+		return authValueEquals(value, match)
+	}
+}
+
+// Check that a match string is well formed, adding compiled regex: matches to a map
+func authCompileMatch(match string, regexes map[string]*regexp.Regexp) error {
+	switch {
+	case strings.HasPrefix(match, "not:"):
+		return authCompileMatch(strings.TrimPrefix(match, "not:"), regexes)
+	case strings.HasPrefix(match, "regex:"):
+		compiled, err := regexp.Compile(strings.TrimPrefix(match, "regex:"))
+		if err != nil {
+			return err
+		}
+		regexes[match] = compiled
+	case strings.HasPrefix(match, "glob:"):
+		if _, err := path.Match(strings.TrimPrefix(match, "glob:"), ""); err != nil {
+			return err
+		}
+	case strings.HasPrefix(match, "between:"):
+		if len(strings.Split(strings.TrimPrefix(match, "between:"), ",")) != 2 {
+			return errors.New("between needs two operands, as between:low,high")
+		}
+	}
+	return nil
+}
+
+// Is a value equal to a string operand, converted to the value type
+func authValueEquals(value interface{}, operand string) (bool, error) {
+	switch typed := value.(type) {
+	case bool:
+		converted, err := ymlTool_Convert_ToBool(operand)
+		return err == nil && converted == typed, err
+	case string:
+		return typed == operand, nil
+	default:
+		compared, err := authValueCompare(value, operand)
+		return err == nil && compared == 0, err
+	}
+}
+
+// Compare a number or duration value to a string operand, returning -1, 0 or 1
+func authValueCompare(value interface{}, operand string) (int, error) {
+	var difference float64
+	switch typed := value.(type) {
+	case float64:
+		converted, err := strconv.ParseFloat(operand, 64)
+		if err != nil {
+			return 0, errors.New(strconv.Quote(operand) + " is not a number")
+		}
+		difference = typed - converted
+	case time.Duration:
+		converted, err := time.ParseDuration(operand)
+		if err != nil {
+			return 0, errors.New(strconv.Quote(operand) + " is not a duration")
+		}
+		difference = float64(typed - converted)
+	default:
+		return 0, fmt.Errorf("values of type %T can only be compared as numbers or durations", value)
+	}
+
+	switch {
+	case difference < 0:
+		return -1, nil
+	case difference > 0:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Convert a value to a string for text matches
+func authValueString(value interface{}) string {
+	switch typed := value.(type) {
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case time.Duration:
+		return typed.String()
 	default:
-		log.WithFields(log.Fields{"Property": prop.Id(), "type": prop.Type()}).Error("Could not perform an authorization match on property as the auth type matching algorythm has not been written")
+		return fmt.Sprint(value)
 	}
-	return false
 }
 
 /**
@@ -0,0 +1,157 @@
+package configwrapper
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+)
+
+// A property which holds a value of any type, to match rules against
+type authTestProperty struct {
+	api_property.StringProperty
+	value interface{}
+}
+
+// Id for the Property
+func (prop *authTestProperty) Id() string {
+	return "test.value"
+}
+
+// Label for the Property
+func (prop *authTestProperty) Label() string {
+	return "Test value"
+}
+
+// Description for the Property
+func (prop *authTestProperty) Description() string {
+	return "A value of any type, for matching against rules."
+}
+
+// Is the Property internal only
+func (prop *authTestProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Get the property value
+func (prop *authTestProperty) Get() interface{} {
+	return prop.value
+}
+
+// Set the property value
+func (prop *authTestProperty) Set(value interface{}) bool {
+	prop.value = value
+	return true
+}
+
+// Copy the property
+func (prop *authTestProperty) Copy() api_property.Property {
+	return api_property.Property(&authTestProperty{value: prop.value})
+}
+
+func TestAuthMatchProperty(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		matches []string
+		matched bool
+		err     bool
+	}{
+		{name: "exact string", value: "dev", matches: []string{"dev"}, matched: true},
+		{name: "different string", value: "prod", matches: []string{"dev"}},
+		{name: "any of several matches", value: "prod", matches: []string{"dev", "prod"}, matched: true},
+		{name: "bytes as a string", value: []byte("dev"), matches: []string{"dev"}, matched: true},
+		{name: "regex", value: "dev-1", matches: []string{"regex:^dev-"}, matched: true},
+		{name: "regex no match", value: "prod-1", matches: []string{"regex:^dev-"}},
+		{name: "prefix", value: "dev-1", matches: []string{"prefix:dev-"}, matched: true},
+		{name: "glob", value: "settings.yml", matches: []string{"glob:*.yml"}, matched: true},
+		{name: "in list", value: "b", matches: []string{"in:a, b,c"}, matched: true},
+		{name: "not in list", value: "d", matches: []string{"in:a,b,c"}},
+		{name: "not", value: "prod", matches: []string{"not:dev"}, matched: true},
+		{name: "list any item", value: []string{"a", "dev"}, matches: []string{"dev"}, matched: true},
+		{name: "list not any item", value: []string{"a", "dev"}, matches: []string{"not:dev"}},
+		{name: "bool true without matches", value: true, matched: true},
+		{name: "bool false without matches", value: false},
+		{name: "bool false match", value: false, matches: []string{"false"}, matched: true},
+		{name: "int greater", value: 10, matches: []string{">5"}, matched: true},
+		{name: "int not greater", value: 5, matches: []string{">5"}},
+		{name: "int at most", value: 5, matches: []string{"<=5"}, matched: true},
+		{name: "int equal", value: int64(5), matches: []string{"5"}, matched: true},
+		{name: "float between", value: 2.5, matches: []string{"between:1, 5"}, matched: true},
+		{name: "float outside between", value: 7.5, matches: []string{"between:1,5"}},
+		{name: "duration less", value: 90 * time.Second, matches: []string{"<2m"}, matched: true},
+		{name: "duration at least", value: 90 * time.Second, matches: []string{">=2m"}},
+		{name: "nil value", value: nil, matches: []string{"dev"}},
+		{name: "string compared as a number", value: "dev", matches: []string{">5"}, err: true},
+		{name: "number equal to text", value: 5, matches: []string{"five"}, err: true},
+		{name: "duration compared to a number", value: time.Minute, matches: []string{"<5"}, err: true},
+		{name: "bool compared to text", value: true, matches: []string{"yes"}, err: true},
+		{name: "between with one operand", value: 3, matches: []string{"between:1"}, err: true},
+		{name: "unsupported type", value: map[string]string{}, matches: []string{"dev"}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prop := &authTestProperty{value: test.value}
+			matched, err := authMatchProperty(prop, test.matches, map[string]*regexp.Regexp{})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if matched != test.matched {
+				t.Errorf("expected matched %v, got %v", test.matched, matched)
+			}
+		})
+	}
+}
+
+func TestAuthValueCompare(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		operand string
+		compare int
+		err     bool
+	}{
+		{name: "number less", value: float64(1), operand: "2", compare: -1},
+		{name: "number equal", value: float64(2), operand: "2.0", compare: 0},
+		{name: "number greater", value: 3.5, operand: "2", compare: 1},
+		{name: "negative number", value: float64(-1), operand: "-2", compare: 1},
+		{name: "duration greater", value: time.Minute, operand: "30s", compare: 1},
+		{name: "duration equal", value: time.Minute, operand: "1m0s", compare: 0},
+		{name: "duration less", value: time.Second, operand: "1m", compare: -1},
+		{name: "number against text", value: float64(1), operand: "one", err: true},
+		{name: "duration without unit", value: time.Minute, operand: "5", err: true},
+		{name: "string value", value: "5", operand: "5", err: true},
+		{name: "bool value", value: true, operand: "1", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compare, err := authValueCompare(test.value, test.operand)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if err == nil && compare != test.compare {
+				t.Errorf("expected %d, got %d", test.compare, compare)
+			}
+		})
+	}
+}
+
+func TestAuthRuleDeniesOnMatchError(t *testing.T) {
+	op, err := New_securityExplainTarget("orchestrate.up", []string{"test.value=dev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := &SecurityConfigWrapperAuthorizeYmlRule{Id: "limit", Operation: "*", Authorize: "allow", Properties: map[string][]string{"test.value": []string{">5"}}}
+
+	trace := SecurityRuleTrace{}
+	if authValue := rule.evaluate(op, SecurityActor{Id: "alice"}, nil, &trace); authValue >= 0 {
+		t.Errorf("expected the rule to deny, got %d", authValue)
+	}
+	if len(trace.Properties) != 1 || trace.Properties[0].Error == "" {
+		t.Errorf("expected the match error in the trace, got %+v", trace.Properties)
+	}
+}
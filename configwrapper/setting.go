@@ -1,7 +1,9 @@
 package configwrapper
 
 import (
+	"context"
 	"errors"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 
@@ -14,20 +16,40 @@ import (
 const (
 	// The Config key for settings
 	CONFIG_KEY_SETTINGS = "settings"
+
+	// The setting key which can hold a project scope precedence chain, like "project-local > project > user"
+	SETTING_KEY_PRECEDENCE = "setting.precedence"
 )
 
 /**
  * This defines what a ConfigWrapper must provide
  * to the settings operations.  This way different wrappers
  * could be used to interpret JSON or YML or whatever.
+ *
+ * SetAll returns a snapshot of the settings from just before
+ * the change, taken under the same lock as the change, so that
+ * callers can tell what each change replaced.
  */
 type SettingsConfigWrapper interface {
 	DefaultScope() string
+	Snapshot() SettingsSnapshot
 	Get(key string) (SettingValues, bool)
 	Set(key string, values SettingValues) bool
+	SetAll(changes map[string]SettingValues) (SettingsSnapshot, bool)
 	List(parent string) []string
 }
 
+// A settings wrapper that can check its config for problems
+type SettingsLinter interface {
+	Lint() ([]SettingLintIssue, error)
+}
+
+// A settings wrapper that can tell subscribers about changes
+type SettingsSubscriber interface {
+	Subscribe(ctx context.Context, handler SettingChangeHandler)
+	SubscribeChannel(ctx context.Context) <-chan SettingChangeEvent
+}
+
 /**
  * The following 2 structs are used to keep track of settings
  * as a string map, but where each value knows from what config
@@ -73,12 +95,23 @@ func (settings *Settings) Set(key string, values SettingValues) {
 
 // Answer if the settings has no assigned values
 func (settings *Settings) Get(key string) (SettingValues, bool) {
-	settings.safe()
-
+	// reading a nil map is safe, so readers never need to initialize
 	values, found := settings.valueMap[key]
 	return values, found
 }
 
+// Make a copy of the settings, which shares no values with the original
+//
+// The original is only read, so that copies can be made under a read lock.
+func (settings *Settings) Copy() Settings {
+	copied := Settings{}
+	copied.safe()
+	for key, values := range settings.valueMap {
+		copied.valueMap[key] = values.Copy()
+	}
+	return copied
+}
+
 // Answer if the settings has no assigned values
 func (settings *Settings) Empty() bool {
 	return settings.valueMap == nil
@@ -86,8 +119,6 @@ func (settings *Settings) Empty() bool {
 
 // Return a list of valid keys for the settings
 func (settings *Settings) Keys() []string {
-	settings.safe()
-
 	keys := []string{}
 	for key, _ := range settings.valueMap {
 		keys = append(keys, key)
@@ -97,8 +128,6 @@ func (settings *Settings) Keys() []string {
 
 // Return a list of valid scopes for the settings
 func (settings *Settings) Scopes() []string {
-	settings.safe()
-
 	scopes := []string{}
 	for _, values := range settings.valueMap {
 		for _, scope := range values.Scopes() {
@@ -117,6 +146,24 @@ func (settings *Settings) Scopes() []string {
 	return scopes
 }
 
+// A consistent view of settings, with the precedence chain and secret
+// keys that were in use when the values were copied
+type SettingsSnapshot struct {
+	Settings
+	precedence []string
+	schema     SettingsSchema
+}
+
+// The scope precedence chain for the snapshot values
+func (snapshot *SettingsSnapshot) Precedence() []string {
+	return append([]string{}, snapshot.precedence...)
+}
+
+// Is a setting secret in the snapshot
+func (snapshot *SettingsSnapshot) Secret(key string) bool {
+	return snapshot.schema.Secret(key)
+}
+
 // S single setting value, but with different values from scope
 type SettingValues struct {
 	settings map[string][]byte
@@ -142,6 +189,18 @@ func (values *SettingValues) Merge(merge SettingValues, override bool) {
 	}
 }
 
+// Make a copy of the values, which shares no values with the original
+func (values *SettingValues) Copy() SettingValues {
+	values.safe()
+
+	copied := SettingValues{}
+	copied.safe()
+	for _, scope := range values.order {
+		copied.Set(scope, append([]byte{}, values.settings[scope]...))
+	}
+	return copied
+}
+
 // Give a slice of all of the scope keys for a SettingValues
 func (values *SettingValues) Scopes() []string {
 	values.safe()
@@ -155,8 +214,10 @@ func (values *SettingValues) Scopes() []string {
 // Get a settings value
 func (values *SettingValues) Set(scope string, value []byte) {
 	values.safe()
+	if _, exists := values.settings[scope]; !exists {
+		values.order = append(values.order, scope)
+	}
 	values.settings[scope] = value
-	values.order = append(values.order, scope)
 }
 
 // Get a settings value
@@ -169,6 +230,36 @@ func (values *SettingValues) Get(scope string) ([]byte, bool) {
 	}
 }
 
+// Resolve a value through a scope precedence chain
+//
+// The first scope in the chain that has a value provides it.  Any
+// scopes that are not in the chain are tried afterwards, in the order
+// in which they were added, so that resolution is always deterministic.
+func (values *SettingValues) Resolve(precedence []string) (string, []byte, bool) {
+	values.safe()
+	for _, scope := range precedence {
+		if scopeValue, found := values.settings[scope]; found {
+			return scope, scopeValue, true
+		}
+	}
+	if len(values.order) > 0 {
+		scope := values.order[0]
+		return scope, values.settings[scope], true
+	}
+	return "", []byte{}, false
+}
+
+// Convert a precedence chain string like "env > project > user" to a slice of scopes
+func ParseSettingPrecedence(chain string) []string {
+	scopes := []string{}
+	for _, scope := range strings.FieldsFunc(chain, func(r rune) bool { return r == '>' || r == ',' }) {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
 /**
  * Actual Operations
  */
@@ -184,6 +275,19 @@ func (get SettingConfigWrapperGetOperation) Validate() api_result.Result {
 	return api_result.MakeSuccessfulResult()
 }
 
+// Get properties, adding a reveal property so that secret values can be explicitly asked for
+//
+// As the reveal property is an operation property, authorization rules
+// can be written to control who may reveal secret values.
+func (get SettingConfigWrapperGetOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Merge(get.BaseSettingGetOperation.Properties())
+	props.Add(api_property.Property(&SettingRevealProperty{}))
+
+	return props.Properties()
+}
+
 // Execute the operation
 // @TODO Make this non-blocking
 func (get SettingConfigWrapperGetOperation) Exec(props api_property.Properties) api_result.Result {
@@ -193,44 +297,39 @@ func (get SettingConfigWrapperGetOperation) Exec(props api_property.Properties)
 	scopeProp, _ := props.Get(api_setting.OPERATION_PROPERTY_SETTING_SCOPE)
 	valueProp, _ := props.Get(api_setting.OPERATION_PROPERTY_SETTING_VALUE)
 
+	reveal := false
+	if revealProp, found := props.Get(OPERATION_PROPERTY_SETTING_REVEAL); found {
+		reveal, _ = revealProp.Get().(bool)
+	}
+
 	if key, ok := keyProp.Get().(string); ok {
-		if value, ok := get.Wrapper.Get(key); ok {
+		// resolve from a single snapshot, so that the value, precedence and secrecy all agree
+		snapshot := get.Wrapper.Snapshot()
+		if value, ok := snapshot.Get(key); ok {
+			secret := snapshot.Secret(key) && !reveal
 
 			/**
 			 * 1. look for a scope property value in the operation, and use it
-			 * 2. try to look for a default scope value, and use it
-			 * 3. iterate through all of the values and return the first one
+			 * 2. resolve the value through the wrapper scope precedence chain,
+			 *    and report which scope provided it
 			 */
 
 			// 1. look for a scope property value
 			if scope, ok := scopeProp.Get().(string); ok && scope != "" {
 				if scopeValue, found := value.Get(scope); found {
-					valueProp.Set(scopeValue)
+					valueProp.Set([]byte(settingMaskValue(secret, scopeValue)))
 				} else {
 					res.MarkFailed()
 					res.AddError(errors.New("Setting connector did not find the value in the scope that you were looking for"))
 				}
+			} else if scope, scopeValue, found := value.Resolve(snapshot.Precedence()); found {
+				// 2. resolve through the precedence chain
+				log.WithFields(log.Fields{"key": key, "scope": scope}).Debug("Setting resolved through scope precedence")
+				scopeProp.Set(scope)
+				valueProp.Set([]byte(settingMaskValue(secret, scopeValue)))
 			} else {
-				// 2. check for a default scope
-				scope = get.Wrapper.DefaultScope()
-
-				if scopeValue, found := value.Get(scope); found {
-					scopeProp.Set(scope)
-					valueProp.Set(scopeValue)
-				} else {
-					// 3. try to take the first value
-					if len(value.Scopes()) > 0 {
-						for _, scope := range value.Scopes() {
-							scopeValue, _ := value.Get(scope)
-							scopeProp.Set(scope)
-							valueProp.Set(scopeValue)
-							break
-						}
-					} else {
-						res.MarkFailed()
-						res.AddError(errors.New("Setting connector did not find any value for the key that you were looking for"))
-					}
-				}
+				res.MarkFailed()
+				res.AddError(errors.New("Setting connector did not find any value for the key that you were looking for"))
 			}
 
 		} else {
@@ -250,9 +349,14 @@ func (get SettingConfigWrapperGetOperation) Exec(props api_property.Properties)
 }
 
 // A Setting Set operation that uses a ConfigWrapper to assign values
+//
+// If an Audit log is given, then each successful set is recorded in it,
+// using the Users source to identify who made the change.
 type SettingConfigWrapperSetOperation struct {
 	api_setting.BaseSettingSetOperation
 	Wrapper SettingsConfigWrapper
+	Audit   SettingAuditLog
+	Users   SettingAuditUserSource
 }
 
 // Validate the operation
@@ -278,11 +382,17 @@ func (set SettingConfigWrapperSetOperation) Exec(props api_property.Properties)
 			values := SettingValues{}
 			values.Set(scope, value)
 
-			if okSet := set.Wrapper.Set(key, values); !okSet {
+			// the previous value comes from the same change, so the audit can't record a stale one
+			before, okSet := set.Wrapper.SetAll(map[string]SettingValues{key: values})
+			if !okSet {
 				res.MarkFailed()
 				res.AddError(errors.New("Failed to set setting value"))
 			} else {
-				log.WithFields(log.Fields{"key": okKey, "scope": scope, "values": values}).Debug("Set config value")
+				existing, _ := before.Get(key)
+				oldValue, oldFound := existing.Get(scope)
+				secret := before.Secret(key)
+				log.WithFields(log.Fields{"key": key, "scope": scope, "value": settingMaskValue(secret, value)}).Debug("Set config value")
+				settingAuditChange(set.Audit, set.Users, key, scope, secret, oldValue, oldFound, value)
 				res.MarkSuccess()
 			}
 		} else {
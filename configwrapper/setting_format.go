@@ -0,0 +1,154 @@
+package configwrapper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ * Encoding and decoding of flat settings maps into formats
+ * that other tools consume: dotenv files, json and shell
+ * export lines.
+ */
+
+const (
+	SETTING_FORMAT_DOTENV = "dotenv"
+	SETTING_FORMAT_JSON   = "json"
+	SETTING_FORMAT_SHELL  = "shell"
+)
+
+// Convert a setting key to an environment variable name (deploy.target => DEPLOY_TARGET)
+func SettingKeyToEnvName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// Convert an environment variable name back to a setting key, preferring known keys
+func SettingEnvNameToKey(name string, knownKeys []string) string {
+	for _, key := range knownKeys {
+		if SettingKeyToEnvName(key) == name {
+			return key
+		}
+	}
+	return strings.Replace(strings.ToLower(name), "_", ".", -1)
+}
+
+// Encode a map of settings in one of the setting formats
+func EncodeSettings(format string, values map[string]string) ([]byte, error) {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buffer bytes.Buffer
+	switch format {
+	case SETTING_FORMAT_JSON:
+		return json.MarshalIndent(values, "", "  ")
+	case SETTING_FORMAT_DOTENV:
+		for _, key := range keys {
+			buffer.WriteString(SettingKeyToEnvName(key) + "=" + settingQuoteDotenv(values[key]) + "\n")
+		}
+	case SETTING_FORMAT_SHELL:
+		for _, key := range keys {
+			buffer.WriteString("export " + SettingKeyToEnvName(key) + "=" + settingQuoteShell(values[key]) + "\n")
+		}
+	default:
+		return []byte{}, errors.New("Unknown settings format: " + format)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decode settings bytes in one of the setting formats into a map
+//
+// Environment variable names from dotenv and shell formats are converted
+// back to setting keys, matching against the passed known keys first.
+func DecodeSettings(format string, source []byte, knownKeys []string) (map[string]string, error) {
+	values := map[string]string{}
+
+	switch format {
+	case SETTING_FORMAT_JSON:
+		if err := json.Unmarshal(source, &values); err != nil {
+			return values, err
+		}
+	case SETTING_FORMAT_DOTENV, SETTING_FORMAT_SHELL:
+		scanner := bufio.NewScanner(bytes.NewReader(source))
+		for lineNumber := 1; scanner.Scan(); lineNumber++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+			separator := strings.Index(line, "=")
+			if separator < 1 {
+				return values, errors.New("Invalid settings line " + strconv.Itoa(lineNumber) + ": " + line)
+			}
+			// single quoted shell values may continue over multiple lines
+			rawValue := strings.TrimSpace(line[separator+1:])
+			for strings.HasPrefix(rawValue, "'") && !settingShellQuoteClosed(rawValue) && scanner.Scan() {
+				lineNumber++
+				rawValue += "\n" + scanner.Text()
+			}
+
+			value, err := settingUnquote(rawValue)
+			if err != nil {
+				return values, errors.New("Invalid settings value on line " + strconv.Itoa(lineNumber) + ": " + err.Error())
+			}
+			values[SettingEnvNameToKey(strings.TrimSpace(line[:separator]), knownKeys)] = value
+		}
+		if err := scanner.Err(); err != nil {
+			return values, err
+		}
+	default:
+		return values, errors.New("Unknown settings format: " + format)
+	}
+	return values, nil
+}
+
+// Quote a value for a dotenv file, only when it is needed
+func settingQuoteDotenv(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"'\\#$=") {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// Quote a value for a posix shell, using single quotes
+func settingQuoteShell(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// Is a single quoted shell value closed, ignoring escaped quotes which reopen the quote
+func settingShellQuoteClosed(value string) bool {
+	value = strings.Replace(value, `'\''`, "", -1)
+	return len(value) >= 2 && strings.HasSuffix(value, "'")
+}
+
+// Remove dotenv or shell quoting from a value
+func settingUnquote(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return value, errors.New("unterminated single quote")
+		}
+		return strings.Replace(value[1:len(value)-1], `'\''`, "'", -1), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,296 @@
+package configwrapper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	api_operation "github.com/wunderkraut/radi-api/operation"
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_result "github.com/wunderkraut/radi-api/result"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+
+	api_security "github.com/wunderkraut/radi-api/operation/security"
+)
+
+/**
+ * Settings change history, which records who changed what
+ * setting, without recording the values themselves.  Secret
+ * settings have no value hashes either, as a hash of a weak
+ * secret, like a password, could be brute forced.
+ */
+
+const (
+	// Operation id for the history operation
+	OPERATION_ID_SETTING_HISTORY = "setting.history"
+
+	// Properties for the history operation
+	OPERATION_PROPERTY_SETTING_HISTORY_KEY     = "setting.history.key"
+	OPERATION_PROPERTY_SETTING_HISTORY_USER    = "setting.history.user"
+	OPERATION_PROPERTY_SETTING_HISTORY_RECORDS = "setting.history.records"
+)
+
+// A log of setting changes, which records can be appended to and read from
+type SettingAuditLog interface {
+	Append(record SettingAuditRecord) error
+	Records() ([]SettingAuditRecord, error)
+}
+
+// Something that can say who the current user is
+type SettingAuditUserSource interface {
+	CurrentUser() api_security.SecurityUser
+}
+
+// A single setting change record
+type SettingAuditRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user"`
+	Key       string    `json:"key"`
+	Scope     string    `json:"scope"`
+	Secret    bool      `json:"secret,omitempty"` // secret settings are recorded without hashes
+	OldHash   string    `json:"old_hash,omitempty"`
+	NewHash   string    `json:"new_hash,omitempty"`
+}
+
+// Hash a setting value for a record, so that changes can be compared without storing values
+func SettingValueHash(value []byte) string {
+	hash := sha256.Sum256(value)
+	return hex.EncodeToString(hash[:])
+}
+
+// Make a change record for a key, from the old and new values
+func New_SettingAuditRecord(user string, key string, scope string, secret bool, oldValue []byte, oldFound bool, newValue []byte) SettingAuditRecord {
+	record := SettingAuditRecord{
+		Timestamp: time.Now(),
+		User:      user,
+		Key:       key,
+		Scope:     scope,
+		Secret:    secret,
+	}
+	if secret {
+		return record
+	}
+	record.NewHash = SettingValueHash(newValue)
+	if oldFound {
+		record.OldHash = SettingValueHash(oldValue)
+	}
+	return record
+}
+
+// Record a setting change in an audit log, which may be nil if auditing is off
+func settingAuditChange(audit SettingAuditLog, users SettingAuditUserSource, key string, scope string, secret bool, oldValue []byte, oldFound bool, newValue []byte) {
+	if audit == nil {
+		return
+	}
+
+	user := "anonymous"
+	if users != nil {
+		if currentUser := users.CurrentUser(); currentUser != nil {
+			user = currentUser.Id()
+		}
+	}
+
+	if err := audit.Append(New_SettingAuditRecord(user, key, scope, secret, oldValue, oldFound, newValue)); err != nil {
+		log.WithError(err).WithFields(log.Fields{"key": key, "scope": scope}).Error("Could not append setting change to the audit log")
+	}
+}
+
+// A Setting History operation that queries a settings audit log
+type SettingConfigWrapperHistoryOperation struct {
+	Audit SettingAuditLog
+}
+
+// Id the operation
+func (history SettingConfigWrapperHistoryOperation) Id() string {
+	return OPERATION_ID_SETTING_HISTORY
+}
+
+// Label the operation
+func (history SettingConfigWrapperHistoryOperation) Label() string {
+	return "Setting history"
+}
+
+// Description for the operation
+func (history SettingConfigWrapperHistoryOperation) Description() string {
+	return "List recorded setting changes, optionally filtered by key or user."
+}
+
+// Help text for the operation
+func (history SettingConfigWrapperHistoryOperation) Help() string {
+	return "Each record holds the time, user, key and scope of a change, and hashes of the old and new values, so that values themselves are never stored.  Changes to secret settings are recorded without hashes."
+}
+
+// Usage for the operation
+func (history SettingConfigWrapperHistoryOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+// Validate the operation
+func (history SettingConfigWrapperHistoryOperation) Validate() api_result.Result {
+	return api_result.MakeSuccessfulResult()
+}
+
+// Get properties
+func (history SettingConfigWrapperHistoryOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SettingHistoryKeyProperty{}))
+	props.Add(api_property.Property(&SettingHistoryUserProperty{}))
+	props.Add(api_property.Property(&SettingHistoryRecordsProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (history SettingConfigWrapperHistoryOperation) Exec(props api_property.Properties) api_result.Result {
+	res := api_result.New_StandardResult()
+
+	keyProp, _ := props.Get(OPERATION_PROPERTY_SETTING_HISTORY_KEY)
+	userProp, _ := props.Get(OPERATION_PROPERTY_SETTING_HISTORY_USER)
+	recordsProp, _ := props.Get(OPERATION_PROPERTY_SETTING_HISTORY_RECORDS)
+
+	key, _ := keyProp.Get().(string)
+	user, _ := userProp.Get().(string)
+
+	if history.Audit == nil {
+		recordsProp.Set([]SettingAuditRecord{})
+		res.MarkSuccess()
+	} else if records, err := history.Audit.Records(); err == nil {
+		matched := []SettingAuditRecord{}
+		for _, record := range records {
+			if (key == "" || record.Key == key) && (user == "" || record.User == user) {
+				matched = append(matched, record)
+			}
+		}
+		recordsProp.Set(matched)
+		res.MarkSuccess()
+	} else {
+		res.MarkFailed()
+		res.AddError(err)
+	}
+
+	res.MarkFinished()
+
+	return res.Result()
+}
+
+/**
+ * Properties
+ */
+
+// Property for filtering history by setting key
+type SettingHistoryKeyProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (key *SettingHistoryKeyProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_HISTORY_KEY
+}
+
+// Label for the Property
+func (key *SettingHistoryKeyProperty) Label() string {
+	return "Setting key"
+}
+
+// Description for the Property
+func (key *SettingHistoryKeyProperty) Description() string {
+	return "Only list changes to this setting key."
+}
+
+// Is the Property internal only
+func (key *SettingHistoryKeyProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (key *SettingHistoryKeyProperty) Copy() api_property.Property {
+	prop := &SettingHistoryKeyProperty{}
+	prop.Set(key.Get())
+	return api_property.Property(prop)
+}
+
+// Property for filtering history by user
+type SettingHistoryUserProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (user *SettingHistoryUserProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_HISTORY_USER
+}
+
+// Label for the Property
+func (user *SettingHistoryUserProperty) Label() string {
+	return "User"
+}
+
+// Description for the Property
+func (user *SettingHistoryUserProperty) Description() string {
+	return "Only list changes made by this user id."
+}
+
+// Is the Property internal only
+func (user *SettingHistoryUserProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (user *SettingHistoryUserProperty) Copy() api_property.Property {
+	prop := &SettingHistoryUserProperty{}
+	prop.Set(user.Get())
+	return api_property.Property(prop)
+}
+
+// Property holding matched setting change records
+type SettingHistoryRecordsProperty struct {
+	value []SettingAuditRecord
+}
+
+// Id for the Property
+func (records *SettingHistoryRecordsProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_HISTORY_RECORDS
+}
+
+// Give an idea of what type of value the property consumes
+func (records *SettingHistoryRecordsProperty) Type() string {
+	return "handler/configwrapper.[]SettingAuditRecord"
+}
+
+// Label for the Property
+func (records *SettingHistoryRecordsProperty) Label() string {
+	return "Setting changes"
+}
+
+// Description for the Property
+func (records *SettingHistoryRecordsProperty) Description() string {
+	return "Recorded setting changes which matched the history filters."
+}
+
+// Is the Property internal only
+func (records *SettingHistoryRecordsProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Property Accessors
+func (records *SettingHistoryRecordsProperty) Get() interface{} {
+	return interface{}(records.value)
+}
+func (records *SettingHistoryRecordsProperty) Set(value interface{}) bool {
+	if converted, ok := value.([]SettingAuditRecord); ok {
+		records.value = converted
+		return true
+	} else {
+		log.WithFields(log.Fields{"value": value}).Error("Could not assign Property value, because the passed parameter was the wrong type. Expected []configwrapper.SettingAuditRecord")
+		return false
+	}
+}
+
+// Copy the property
+func (records *SettingHistoryRecordsProperty) Copy() api_property.Property {
+	prop := &SettingHistoryRecordsProperty{}
+	prop.Set(records.Get())
+	return api_property.Property(prop)
+}
@@ -0,0 +1,350 @@
+package configwrapper
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	api_operation "github.com/wunderkraut/radi-api/operation"
+	api_config "github.com/wunderkraut/radi-api/operation/config"
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_result "github.com/wunderkraut/radi-api/result"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+)
+
+/**
+ * Settings linting, which checks all settings scopes for
+ * problems that loading would otherwise hide.
+ */
+
+const (
+	// Operation id for the lint operation
+	OPERATION_ID_SETTING_LINT = "setting.lint"
+
+	// Property for the issues found by the lint operation
+	OPERATION_PROPERTY_SETTING_LINT_ISSUES = "setting.lint.issues"
+
+	// Lint issue severities
+	SETTING_LINT_ERROR   = "error"
+	SETTING_LINT_WARNING = "warning"
+	SETTING_LINT_NOTICE  = "notice"
+)
+
+// Setting keys which code reads, as keys or patterns such as db.*, @see RegisterSettingReader
+var settingReaders = struct {
+	lock     sync.RWMutex
+	patterns []string
+}{patterns: []string{SETTING_KEY_PRECEDENCE}}
+
+// Register setting keys which code reads, as keys or path.Match patterns
+// such as db.*, so that lint does not report them as keys nobody reads
+func RegisterSettingReader(patterns ...string) {
+	settingReaders.lock.Lock()
+	defer settingReaders.lock.Unlock()
+	settingReaders.patterns = append(settingReaders.patterns, patterns...)
+}
+
+// Does a key match any registered reader, or any of some extra reader patterns
+func settingKeyIsRead(key string, extra []string) bool {
+	settingReaders.lock.RLock()
+	patterns := append(append([]string{}, settingReaders.patterns...), extra...)
+	settingReaders.lock.RUnlock()
+
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, key); match || pattern == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the setting keys read by the When conditions of project components and authorization rules
+func settingConditionKeys(wrapper api_config.ConfigWrapper) []string {
+	keys := []string{}
+	if sources, err := wrapper.Get(CONFIG_KEY_BUILDER); err == nil {
+		for _, scope := range sources.Order() {
+			scopedSource, _ := sources.Get(scope)
+			definition := Yml_ProjectDefintion{}
+			if err := yaml.Unmarshal(scopedSource, &definition); err == nil {
+				for _, component := range definition.Components {
+					keys = append(keys, component.When.SettingKeys()...)
+				}
+			}
+		}
+	}
+	if sources, err := wrapper.Get(CONFIG_KEY_SECURITY_AUTHORIZE); err == nil {
+		for _, scope := range sources.Order() {
+			scopedSource, _ := sources.Get(scope)
+			definition := SecurityConfigWrapperAuthorizeYmlDefinition{}
+			if err := yaml.Unmarshal(scopedSource, &definition); err == nil {
+				for _, rule := range definition.SourceRules {
+					keys = append(keys, rule.When.SettingKeys()...)
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// yaml.v2 errors report lines as "yaml: line 3: ..."
+var settingLintYamlLine = regexp.MustCompile(`line (\d+)`)
+
+// A single problem found in settings
+type SettingLintIssue struct {
+	Severity string
+	Scope    string
+	File     string
+	Line     int
+	Key      string
+	Message  string
+}
+
+// Convert the issue to a readable string
+func (issue SettingLintIssue) String() string {
+	location := issue.File
+	if issue.Line > 0 {
+		location += ":" + strconv.Itoa(issue.Line)
+	}
+	if issue.Scope != "" {
+		location = "[" + issue.Scope + "] " + location
+	}
+	if issue.Key != "" {
+		return fmt.Sprintf("%s %s: %s: %s", issue.Severity, location, issue.Key, issue.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", issue.Severity, location, issue.Message)
+}
+
+// The file that a config key is read from for a scope, so that profile
+// overlay scopes like project.stage report settings.stage.yml
+func settingScopeFileName(key string, scope string) string {
+	if dot := strings.Index(scope, "."); dot > 0 {
+		return key + "." + strings.ToLower(scope[dot+1:]) + ".yml"
+	}
+	return key + ".yml"
+}
+
+// Check a single scope of yml settings source, returning issues and any valid values
+func lintSettingsScope(scope string, file string, source []byte, schema SettingsSchema) ([]SettingLintIssue, map[string]string) {
+	issues := []SettingLintIssue{}
+	values := map[string]string{}
+	lines := ymlTool_SplitLines(source)
+
+	lineOf := func(key string) int {
+		start, _ := ymlTool_FindFlatKey(lines, key)
+		return start + 1
+	}
+
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(source, &raw); err != nil {
+		// parse each top level entry on its own, so that one syntax error doesn't drop the whole scope
+		raw = map[string]interface{}{}
+		for _, entryLines := range ymlTool_FlatEntries(lines) {
+			entry := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(strings.Join(lines[entryLines[0]:entryLines[1]], "\n")), &entry); err != nil {
+				line := entryLines[0] + 1
+				if match := settingLintYamlLine.FindStringSubmatch(err.Error()); match != nil {
+					offset, _ := strconv.Atoi(match[1])
+					line += offset - 1
+				}
+				issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_ERROR, Scope: scope, File: file, Line: line, Message: err.Error()})
+				continue
+			}
+			for key, value := range entry {
+				raw[key] = value
+			}
+		}
+		if len(issues) == 0 {
+			// the error was not inside any single entry, so nothing can be trusted
+			line := 0
+			if match := settingLintYamlLine.FindStringSubmatch(err.Error()); match != nil {
+				line, _ = strconv.Atoi(match[1])
+			}
+			issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_ERROR, Scope: scope, File: file, Line: line, Message: err.Error()})
+			return issues, values
+		}
+	}
+
+	keys := []string{}
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch raw[key].(type) {
+		case map[interface{}]interface{}, []interface{}:
+			issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_ERROR, Scope: scope, File: file, Line: lineOf(key), Key: key, Message: "value is not a scalar, settings must be a flat map"})
+			continue
+		}
+
+		// unmarshal the single entry as a string, the same way that loading does
+		value := ""
+		if raw[key] != nil {
+			value = fmt.Sprint(raw[key])
+		}
+		if start, end := ymlTool_FindFlatKey(lines, key); start >= 0 {
+			entry := map[string]string{}
+			if err := yaml.Unmarshal([]byte(strings.Join(lines[start:end], "\n")), &entry); err == nil {
+				value = entry[key]
+			}
+		}
+		values[key] = value
+
+		if len(schema.Settings) > 0 {
+			if keySchema, known := schema.Get(key); !known {
+				issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_WARNING, Scope: scope, File: file, Line: lineOf(key), Key: key, Message: "key is not in the settings schema"})
+			} else if err := settingCheckType(keySchema.Type, value); err != nil {
+				issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_ERROR, Scope: scope, File: file, Line: lineOf(key), Key: key, Message: err.Error()})
+			}
+		}
+	}
+
+	return issues, values
+}
+
+// Check that a string setting value can be interpreted as a schema type
+func settingCheckType(valueType string, value string) error {
+	var err error
+	switch strings.ToLower(valueType) {
+	case "", "string":
+		return nil
+	case "int", "integer":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "float", "number":
+		_, err = strconv.ParseFloat(value, 64)
+	case "bool", "boolean":
+		_, err = strconv.ParseBool(value)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	default:
+		return errors.New("schema type " + valueType + " is not a known type")
+	}
+	if err != nil {
+		return errors.New("value '" + value + "' is not a valid " + valueType)
+	}
+	return nil
+}
+
+// A Setting Lint operation that checks all settings scopes
+type SettingConfigWrapperLintOperation struct {
+	Wrapper SettingsLinter
+}
+
+// Id the operation
+func (lint SettingConfigWrapperLintOperation) Id() string {
+	return OPERATION_ID_SETTING_LINT
+}
+
+// Label the operation
+func (lint SettingConfigWrapperLintOperation) Label() string {
+	return "Lint settings"
+}
+
+// Description for the operation
+func (lint SettingConfigWrapperLintOperation) Description() string {
+	return "Check all settings scopes for syntax errors, unknown keys, invalid values and conflicts."
+}
+
+// Help text for the operation
+func (lint SettingConfigWrapperLintOperation) Help() string {
+	return "Settings are read fresh from config, without any cached values.  Keys are checked against the settings schema, if there is one, and keys which no registered reader or When condition reads are noted.  The operation fails if any error level issue is found."
+}
+
+// Usage for the operation
+func (lint SettingConfigWrapperLintOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+// Validate the operation
+func (lint SettingConfigWrapperLintOperation) Validate() api_result.Result {
+	return api_result.MakeSuccessfulResult()
+}
+
+// Get properties
+func (lint SettingConfigWrapperLintOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SettingLintIssuesProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (lint SettingConfigWrapperLintOperation) Exec(props api_property.Properties) api_result.Result {
+	res := api_result.New_StandardResult()
+
+	issuesProp, _ := props.Get(OPERATION_PROPERTY_SETTING_LINT_ISSUES)
+
+	issues, err := lint.Wrapper.Lint()
+	if err != nil {
+		res.MarkFailed()
+		res.AddError(err)
+		res.MarkFinished()
+		return res.Result()
+	}
+
+	errorCount := 0
+	issueStrings := []string{}
+	for _, issue := range issues {
+		if issue.Severity == SETTING_LINT_ERROR {
+			errorCount++
+		}
+		issueStrings = append(issueStrings, issue.String())
+	}
+	issuesProp.Set(issueStrings)
+
+	if errorCount > 0 {
+		res.MarkFailed()
+		res.AddError(errors.New("Settings lint found " + strconv.Itoa(errorCount) + " error(s)"))
+	} else {
+		res.MarkSuccess()
+	}
+
+	res.MarkFinished()
+
+	return res.Result()
+}
+
+/**
+ * Properties
+ */
+
+// Property for the issues found by the lint operation
+type SettingLintIssuesProperty struct {
+	api_property.StringSliceProperty
+}
+
+// Id for the Property
+func (issues *SettingLintIssuesProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_LINT_ISSUES
+}
+
+// Label for the Property
+func (issues *SettingLintIssuesProperty) Label() string {
+	return "Lint issues"
+}
+
+// Description for the Property
+func (issues *SettingLintIssuesProperty) Description() string {
+	return "Problems found in the settings."
+}
+
+// Is the Property internal only
+func (issues *SettingLintIssuesProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (issues *SettingLintIssuesProperty) Copy() api_property.Property {
+	prop := &SettingLintIssuesProperty{}
+	prop.Set(issues.Get())
+	return api_property.Property(prop)
+}
@@ -0,0 +1,83 @@
+package configwrapper
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	api_config "github.com/wunderkraut/radi-api/operation/config"
+)
+
+/**
+ * A settings schema describes known settings, so that
+ * they can be typed, documented and flagged as secret.
+ */
+
+const (
+	// The Config key for the settings schema
+	CONFIG_KEY_SETTINGS_SCHEMA = "settings-schema"
+)
+
+// The settings schema, a map of setting key to a schema definition
+type SettingsSchema struct {
+	Settings map[string]SettingSchema `yaml:"Settings"`
+}
+
+// Schema definition for a single setting
+type SettingSchema struct {
+	Type        string `yaml:"Type"`
+	Description string `yaml:"Description"`
+	Secret      bool   `yaml:"Secret"`
+}
+
+// Safe initialize this struct
+func (schema *SettingsSchema) safe() {
+	if schema.Settings == nil {
+		schema.Settings = map[string]SettingSchema{}
+	}
+}
+
+// Get the schema definition for a setting key
+func (schema *SettingsSchema) Get(key string) (SettingSchema, bool) {
+	keySchema, found := schema.Settings[key]
+	return keySchema, found
+}
+
+// Merge in another schema, without overriding existing definitions
+func (schema *SettingsSchema) Merge(merge SettingsSchema) {
+	schema.safe()
+	for key, keySchema := range merge.Settings {
+		if _, exists := schema.Settings[key]; !exists {
+			schema.Settings[key] = keySchema
+		}
+	}
+}
+
+// Is a setting key secret, either by schema or by naming convention
+func (schema *SettingsSchema) Secret(key string) bool {
+	if keySchema, found := schema.Get(key); found && keySchema.Secret {
+		return true
+	}
+	return SettingKeyIsSecret(key)
+}
+
+// Load a settings schema from all scopes of a ConfigWrapper (earlier scopes win)
+func LoadSettingsSchema(wrapper api_config.ConfigWrapper) (SettingsSchema, error) {
+	schema := SettingsSchema{}
+	schema.safe()
+
+	sources, err := wrapper.Get(CONFIG_KEY_SETTINGS_SCHEMA)
+	if err != nil {
+		return schema, err
+	}
+
+	for _, scope := range sources.Order() {
+		scopedSource, _ := sources.Get(scope)
+		scopedSchema := SettingsSchema{}
+		if err := yaml.Unmarshal(scopedSource, &scopedSchema); err == nil {
+			schema.Merge(scopedSchema)
+		} else {
+			log.WithError(err).WithFields(log.Fields{"scope": scope}).Error("Couldn't unmarshall settings schema yml scope")
+		}
+	}
+	return schema, nil
+}
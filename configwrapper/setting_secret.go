@@ -0,0 +1,86 @@
+package configwrapper
+
+import (
+	"path"
+
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+)
+
+/**
+ * Secret settings are settings whose values should not
+ * be shown unless explicitly asked for.  A setting is
+ * secret if the settings schema marks it as secret, or
+ * if its key matches one of the secret naming patterns.
+ */
+
+const (
+	// Property key for the setting reveal property
+	OPERATION_PROPERTY_SETTING_REVEAL = "setting.reveal"
+
+	// The replacement shown for a secret value
+	SETTING_SECRET_MASK = "********"
+)
+
+// Setting key patterns which are always considered secret
+var SettingSecretPatterns = []string{
+	"password",
+	"*.password",
+	"*.secret",
+	"*.token",
+	"*.private_key",
+}
+
+// Does a setting key match one of the secret naming patterns
+func SettingKeyIsSecret(key string) bool {
+	for _, pattern := range SettingSecretPatterns {
+		if match, _ := path.Match(pattern, key); match {
+			return true
+		}
+	}
+	return false
+}
+
+// Return a loggable version of a value, masking it if it is secret
+func settingMaskValue(secret bool, value []byte) string {
+	if secret {
+		return SETTING_SECRET_MASK
+	}
+	return string(value)
+}
+
+/**
+ * Properties
+ */
+
+// Property which explicitly asks for secret setting values to be revealed
+type SettingRevealProperty struct {
+	api_property.BoolProperty
+}
+
+// Id for the Property
+func (reveal *SettingRevealProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_REVEAL
+}
+
+// Label for the Property
+func (reveal *SettingRevealProperty) Label() string {
+	return "Reveal secret values"
+}
+
+// Description for the Property
+func (reveal *SettingRevealProperty) Description() string {
+	return "Reveal the value of settings which are marked as secret, instead of masking them."
+}
+
+// Is the Property internal only
+func (reveal *SettingRevealProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (reveal *SettingRevealProperty) Copy() api_property.Property {
+	prop := &SettingRevealProperty{}
+	prop.Set(reveal.Get())
+	return api_property.Property(prop)
+}
@@ -0,0 +1,323 @@
+package configwrapper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+/**
+ * Setting change subscriptions, so that long running
+ * consumers can react to changes, whether made through
+ * the wrapper, or on disk.
+ */
+
+const (
+	// How often config is checked for changes made outside of the wrapper
+	SETTING_WATCH_INTERVAL = 2 * time.Second
+
+	// Sources of a setting change
+	SETTING_CHANGE_SOURCE_SET    = "set"
+	SETTING_CHANGE_SOURCE_RELOAD = "reload"
+)
+
+// A change to a single setting value in a single scope
+type SettingChangeEvent struct {
+	Key      string
+	Scope    string
+	OldValue []byte
+	NewValue []byte
+	OldFound bool   // false if the value was added
+	NewFound bool   // false if the value was removed
+	Source   string // what caused the change: set or reload
+}
+
+// A callback which receives setting change events
+type SettingChangeHandler func(event SettingChangeEvent)
+
+// Compare two sets of settings, and list the changed values
+func settingsChanges(before Settings, after Settings, source string) []SettingChangeEvent {
+	events := []SettingChangeEvent{}
+
+	keys := before.Keys()
+	for _, key := range after.Keys() {
+		if _, found := before.Get(key); !found {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		beforeValues, _ := before.Get(key)
+		afterValues, _ := after.Get(key)
+
+		scopes := beforeValues.Scopes()
+		for _, scope := range afterValues.Scopes() {
+			if _, found := beforeValues.Get(scope); !found {
+				scopes = append(scopes, scope)
+			}
+		}
+
+		for _, scope := range scopes {
+			oldValue, oldFound := beforeValues.Get(scope)
+			newValue, newFound := afterValues.Get(scope)
+			if oldFound != newFound || string(oldValue) != string(newValue) {
+				events = append(events, SettingChangeEvent{Key: key, Scope: scope, OldValue: oldValue, NewValue: newValue, OldFound: oldFound, NewFound: newFound, Source: source})
+			}
+		}
+	}
+	return events
+}
+
+// A set of subscriptions to setting changes
+type settingSubscriptions struct {
+	subscriptions map[int]*settingSubscription
+	nextId        int
+	lock          sync.Mutex
+}
+
+// A single subscription, which delivers its events in order from its own goroutine
+//
+// Events are queued without blocking, so that a slow handler never
+// holds up the change that caused the event.
+type settingSubscription struct {
+	handler SettingChangeHandler
+	queue   []SettingChangeEvent
+	wake    chan struct{}
+	lock    sync.Mutex
+}
+
+// Add a handler, which is removed when the context is done
+func (subscriptions *settingSubscriptions) Add(ctx context.Context, handler SettingChangeHandler) {
+	subscription := &settingSubscription{
+		handler: handler,
+		wake:    make(chan struct{}, 1),
+	}
+
+	subscriptions.lock.Lock()
+	if subscriptions.subscriptions == nil {
+		subscriptions.subscriptions = map[int]*settingSubscription{}
+	}
+	id := subscriptions.nextId
+	subscriptions.nextId++
+	subscriptions.subscriptions[id] = subscription
+	subscriptions.lock.Unlock()
+
+	go func() {
+		subscription.deliver(ctx)
+		subscriptions.lock.Lock()
+		delete(subscriptions.subscriptions, id)
+		subscriptions.lock.Unlock()
+	}()
+}
+
+// Are there any subscriptions
+func (subscriptions *settingSubscriptions) Empty() bool {
+	subscriptions.lock.Lock()
+	defer subscriptions.lock.Unlock()
+	return len(subscriptions.subscriptions) == 0
+}
+
+// Queue events for all handlers, without waiting for them to be handled
+func (subscriptions *settingSubscriptions) Publish(events []SettingChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	subscriptions.lock.Lock()
+	defer subscriptions.lock.Unlock()
+	for _, subscription := range subscriptions.subscriptions {
+		subscription.push(events)
+	}
+}
+
+// Queue events for the handler
+func (subscription *settingSubscription) push(events []SettingChangeEvent) {
+	subscription.lock.Lock()
+	subscription.queue = append(subscription.queue, events...)
+	subscription.lock.Unlock()
+
+	select {
+	case subscription.wake <- struct{}{}:
+	default: // already woken
+	}
+}
+
+// Pass queued events to the handler, until the context is done
+func (subscription *settingSubscription) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-subscription.wake:
+		}
+
+		subscription.lock.Lock()
+		events := subscription.queue
+		subscription.queue = nil
+		subscription.lock.Unlock()
+
+		for _, event := range events {
+			if ctx.Err() != nil {
+				return
+			}
+			subscription.handler(event)
+		}
+	}
+}
+
+/**
+ * Subscription methods for the yml settings wrapper
+ */
+
+// Set the context for the wrapper, which ends all subscriptions and watching when done
+func (setting *BaseSettingConfigWrapperYmlOperation) SetContext(ctx context.Context) {
+	setting.lock.Lock()
+	defer setting.lock.Unlock()
+	setting.context = ctx
+}
+
+// Get the context for the wrapper
+func (setting *BaseSettingConfigWrapperYmlOperation) Context() context.Context {
+	setting.lock.RLock()
+	defer setting.lock.RUnlock()
+	if setting.context == nil {
+		return context.Background()
+	}
+	return setting.context
+}
+
+// Subscribe a callback to setting changes, until the context (or the wrapper context) is done
+//
+// Subscribing also starts watching config for changes made outside of
+// the wrapper, such as edits to files on disk.  Each handler is called
+// in order from its own goroutine, so a slow handler doesn't block Set.
+func (setting *BaseSettingConfigWrapperYmlOperation) Subscribe(ctx context.Context, handler SettingChangeHandler) {
+	// load before watching, so that the first poll compares against the loaded sources
+	setting.safe()
+
+	wrapperCtx := setting.Context()
+	subscriptionCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-wrapperCtx.Done():
+			cancel()
+		case <-subscriptionCtx.Done():
+		}
+	}()
+
+	setting.subscriptions.Add(subscriptionCtx, handler)
+
+	setting.lock.Lock()
+	defer setting.lock.Unlock()
+	if !setting.watching {
+		setting.watching = true
+		go setting.watch(SETTING_WATCH_INTERVAL)
+	}
+}
+
+// Subscribe to setting changes as a channel, which is closed when the context is done
+func (setting *BaseSettingConfigWrapperYmlOperation) SubscribeChannel(ctx context.Context) <-chan SettingChangeEvent {
+	events := make(chan SettingChangeEvent, 16)
+	subscriptionCtx, cancel := context.WithCancel(ctx)
+
+	// the channel is closed under the lock, so that no handler sends after it is closed
+	var lock sync.Mutex
+	closed := false
+	setting.Subscribe(subscriptionCtx, func(event SettingChangeEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case events <- event:
+		case <-subscriptionCtx.Done():
+		}
+	})
+
+	go func() {
+		select {
+		case <-subscriptionCtx.Done():
+		case <-setting.Context().Done():
+			cancel()
+		}
+		lock.Lock()
+		closed = true
+		close(events)
+		lock.Unlock()
+	}()
+
+	return events
+}
+
+// Poll config for changes made outside of the wrapper, until the wrapper context is done
+//
+// The context is read again on each poll, so that a context set after
+// the first subscription is used.
+func (setting *BaseSettingConfigWrapperYmlOperation) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer func() {
+		// a later subscription can start watching again
+		setting.lock.Lock()
+		setting.watching = false
+		setting.lock.Unlock()
+	}()
+
+	for {
+		select {
+		case <-setting.Context().Done():
+			return
+		case <-ticker.C:
+			if setting.Context().Err() != nil {
+				return
+			}
+			if setting.subscriptions.Empty() {
+				continue
+			}
+			if changed, err := setting.sourcesChanged(); err != nil {
+				log.WithError(err).Debug("Could not check settings config for changes")
+			} else if changed {
+				events, _ := setting.reloadChanges()
+				setting.subscriptions.Publish(events)
+			}
+		}
+	}
+}
+
+// Have the config sources changed since they were last loaded
+func (setting *BaseSettingConfigWrapperYmlOperation) sourcesChanged() (bool, error) {
+	sources, err := setting.wrapper.Get(CONFIG_KEY_SETTINGS)
+	if err != nil {
+		return false, err
+	}
+
+	setting.lock.RLock()
+	defer setting.lock.RUnlock()
+
+	if len(sources.Order()) != len(setting.sources.Order()) {
+		return true, nil
+	}
+	for _, scope := range sources.Order() {
+		source, _ := sources.Get(scope)
+		loaded, found := setting.sources.Get(scope)
+		if !found || string(source) != string(loaded) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Reload the settings, returning change events for any changed values
+func (setting *BaseSettingConfigWrapperYmlOperation) reloadChanges() ([]SettingChangeEvent, error) {
+	setting.lock.Lock()
+	defer setting.lock.Unlock()
+
+	before := setting.settings
+	if err := setting.load(); err != nil {
+		return nil, err
+	}
+	return settingsChanges(before, setting.settings, SETTING_CHANGE_SOURCE_RELOAD), nil
+}
@@ -0,0 +1,434 @@
+package configwrapper
+
+import (
+	"errors"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+
+	api_operation "github.com/wunderkraut/radi-api/operation"
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_result "github.com/wunderkraut/radi-api/result"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+)
+
+/**
+ * Operations that move settings in and out of a
+ * SettingsConfigWrapper, using the setting formats
+ * from setting_format.go
+ */
+
+const (
+	// Operation ids
+	OPERATION_ID_SETTING_EXPORT = "setting.export"
+	OPERATION_ID_SETTING_IMPORT = "setting.import"
+
+	// Properties for settings import/export
+	OPERATION_PROPERTY_SETTING_FORMAT    = "setting.format"
+	OPERATION_PROPERTY_SETTING_DATA      = "setting.data"
+	OPERATION_PROPERTY_SETTING_DRYRUN    = "setting.dryrun"
+	OPERATION_PROPERTY_SETTING_CONFLICTS = "setting.conflicts"
+	OPERATION_PROPERTY_SETTING_FROMSCOPE = "setting.transfer.scope"
+)
+
+// A Setting Export operation that uses a ConfigWrapper to output settings in a format
+type SettingConfigWrapperExportOperation struct {
+	Wrapper SettingsConfigWrapper
+}
+
+// Id the operation
+func (export SettingConfigWrapperExportOperation) Id() string {
+	return OPERATION_ID_SETTING_EXPORT
+}
+
+// Label the operation
+func (export SettingConfigWrapperExportOperation) Label() string {
+	return "Export settings"
+}
+
+// Description for the operation
+func (export SettingConfigWrapperExportOperation) Description() string {
+	return "Export resolved settings, or the settings of a single scope, as dotenv, json or shell export lines."
+}
+
+// Help text for the operation
+func (export SettingConfigWrapperExportOperation) Help() string {
+	return "Dotenv and shell formats convert setting keys to environment variable names, so deploy.target becomes DEPLOY_TARGET.  Secret settings are masked unless the reveal property is set."
+}
+
+// Usage for the operation
+func (export SettingConfigWrapperExportOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+// Validate the operation
+func (export SettingConfigWrapperExportOperation) Validate() api_result.Result {
+	return api_result.MakeSuccessfulResult()
+}
+
+// Get properties
+func (export SettingConfigWrapperExportOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SettingFormatProperty{}))
+	props.Add(api_property.Property(&SettingTransferScopeProperty{}))
+	props.Add(api_property.Property(&SettingRevealProperty{}))
+	props.Add(api_property.Property(&SettingDataProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (export SettingConfigWrapperExportOperation) Exec(props api_property.Properties) api_result.Result {
+	res := api_result.New_StandardResult()
+
+	formatProp, _ := props.Get(OPERATION_PROPERTY_SETTING_FORMAT)
+	scopeProp, _ := props.Get(OPERATION_PROPERTY_SETTING_FROMSCOPE)
+	revealProp, _ := props.Get(OPERATION_PROPERTY_SETTING_REVEAL)
+	dataProp, _ := props.Get(OPERATION_PROPERTY_SETTING_DATA)
+
+	format, _ := formatProp.Get().(string)
+	if format == "" {
+		format = SETTING_FORMAT_DOTENV
+	}
+	scope, _ := scopeProp.Get().(string)
+	reveal, _ := revealProp.Get().(bool)
+
+	// export from a single snapshot, so that a concurrent change can't mix values
+	snapshot := export.Wrapper.Snapshot()
+	precedence := snapshot.Precedence()
+	values := map[string]string{}
+	for _, key := range snapshot.Keys() {
+		settingValues, _ := snapshot.Get(key)
+
+		var value []byte
+		var found bool
+		if scope != "" {
+			value, found = settingValues.Get(scope)
+		} else {
+			_, value, found = settingValues.Resolve(precedence)
+		}
+
+		if found {
+			values[key] = settingMaskValue(snapshot.Secret(key) && !reveal, value)
+		}
+	}
+
+	if data, err := EncodeSettings(format, values); err == nil {
+		dataProp.Set(data)
+		res.MarkSuccess()
+	} else {
+		res.MarkFailed()
+		res.AddError(err)
+	}
+
+	res.MarkFinished()
+
+	return res.Result()
+}
+
+// A Setting Import operation that uses a ConfigWrapper to read formatted settings into a scope
+//
+// If an Audit log is given, then each imported value is recorded in it,
+// in the same way as the Set operation records a change.
+type SettingConfigWrapperImportOperation struct {
+	Wrapper SettingsConfigWrapper
+	Audit   SettingAuditLog
+	Users   SettingAuditUserSource
+}
+
+// Id the operation
+func (imp SettingConfigWrapperImportOperation) Id() string {
+	return OPERATION_ID_SETTING_IMPORT
+}
+
+// Label the operation
+func (imp SettingConfigWrapperImportOperation) Label() string {
+	return "Import settings"
+}
+
+// Description for the operation
+func (imp SettingConfigWrapperImportOperation) Description() string {
+	return "Import settings from dotenv, json or shell export lines into a scope."
+}
+
+// Help text for the operation
+func (imp SettingConfigWrapperImportOperation) Help() string {
+	return "Existing values in the target scope which differ from the imported values are reported as conflicts, and replaced.  Masked secret values from an export are skipped, and all values are saved together, so a failed import changes nothing.  Imported values are recorded in the setting history.  Use the dry-run property to only report what would change."
+}
+
+// Usage for the operation
+func (imp SettingConfigWrapperImportOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+// Validate the operation
+func (imp SettingConfigWrapperImportOperation) Validate() api_result.Result {
+	return api_result.MakeSuccessfulResult()
+}
+
+// Get properties
+func (imp SettingConfigWrapperImportOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&SettingFormatProperty{}))
+	props.Add(api_property.Property(&SettingTransferScopeProperty{}))
+	props.Add(api_property.Property(&SettingDataProperty{}))
+	props.Add(api_property.Property(&SettingDryRunProperty{}))
+	props.Add(api_property.Property(&SettingConflictsProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (imp SettingConfigWrapperImportOperation) Exec(props api_property.Properties) api_result.Result {
+	res := api_result.New_StandardResult()
+
+	formatProp, _ := props.Get(OPERATION_PROPERTY_SETTING_FORMAT)
+	scopeProp, _ := props.Get(OPERATION_PROPERTY_SETTING_FROMSCOPE)
+	dataProp, _ := props.Get(OPERATION_PROPERTY_SETTING_DATA)
+	dryRunProp, _ := props.Get(OPERATION_PROPERTY_SETTING_DRYRUN)
+	conflictsProp, _ := props.Get(OPERATION_PROPERTY_SETTING_CONFLICTS)
+
+	format, _ := formatProp.Get().(string)
+	if format == "" {
+		format = SETTING_FORMAT_DOTENV
+	}
+	scope, _ := scopeProp.Get().(string)
+	if scope == "" {
+		scope = imp.Wrapper.DefaultScope()
+	}
+	dryRun, _ := dryRunProp.Get().(bool)
+	data, _ := dataProp.Get().([]byte)
+
+	// compare against one snapshot, so that every key is checked against the same values
+	snapshot := imp.Wrapper.Snapshot()
+
+	values, err := DecodeSettings(format, data, snapshot.Keys())
+	if err != nil {
+		res.MarkFailed()
+		res.AddError(err)
+		res.MarkFinished()
+		return res.Result()
+	}
+
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conflicts := []string{}
+	changes := map[string]SettingValues{}
+	for _, key := range keys {
+		value := values[key]
+		secret := snapshot.Secret(key)
+
+		// a masked export of a secret holds no value, so it must not replace the real one
+		if secret && value == SETTING_SECRET_MASK {
+			log.WithFields(log.Fields{"key": key, "scope": scope}).Warn("Not importing masked secret setting value")
+			continue
+		}
+
+		if existing, found := snapshot.Get(key); found {
+			if existingValue, found := existing.Get(scope); found && string(existingValue) != value {
+				conflicts = append(conflicts, key+": '"+settingMaskValue(secret, existingValue)+"' => '"+settingMaskValue(secret, []byte(value))+"'")
+			} else if found {
+				continue // unchanged, so there is no need to save it
+			}
+		}
+
+		scopedValues := SettingValues{}
+		scopedValues.Set(scope, []byte(value))
+		changes[key] = scopedValues
+	}
+
+	conflictsProp.Set(conflicts)
+	log.WithFields(log.Fields{"scope": scope, "count": len(changes), "conflicts": len(conflicts), "dry-run": dryRun}).Debug("Imported settings")
+
+	// all values are set together, so that a failure doesn't leave the scope half imported
+	if dryRun || len(changes) == 0 {
+		res.MarkSuccess()
+	} else if before, success := imp.Wrapper.SetAll(changes); success {
+		for _, key := range keys {
+			if change, found := changes[key]; found {
+				existing, _ := before.Get(key)
+				oldValue, oldFound := existing.Get(scope)
+				newValue, _ := change.Get(scope)
+				settingAuditChange(imp.Audit, imp.Users, key, scope, before.Secret(key), oldValue, oldFound, newValue)
+			}
+		}
+		res.MarkSuccess()
+	} else {
+		res.MarkFailed()
+		res.AddError(errors.New("Failed to import setting values into scope " + scope))
+	}
+
+	res.MarkFinished()
+
+	return res.Result()
+}
+
+/**
+ * Properties
+ */
+
+// Property for the format used to export and import settings
+type SettingFormatProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (format *SettingFormatProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_FORMAT
+}
+
+// Label for the Property
+func (format *SettingFormatProperty) Label() string {
+	return "Settings format"
+}
+
+// Description for the Property
+func (format *SettingFormatProperty) Description() string {
+	return "Format of the settings data: dotenv, json or shell (default dotenv)."
+}
+
+// Is the Property internal only
+func (format *SettingFormatProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (format *SettingFormatProperty) Copy() api_property.Property {
+	prop := &SettingFormatProperty{}
+	prop.Set(format.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the single scope to export from, or import into
+type SettingTransferScopeProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (scope *SettingTransferScopeProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_FROMSCOPE
+}
+
+// Label for the Property
+func (scope *SettingTransferScopeProperty) Label() string {
+	return "Settings scope"
+}
+
+// Description for the Property
+func (scope *SettingTransferScopeProperty) Description() string {
+	return "Scope to export from or import into.  Exports resolve all scopes if empty, and imports use the default scope."
+}
+
+// Is the Property internal only
+func (scope *SettingTransferScopeProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (scope *SettingTransferScopeProperty) Copy() api_property.Property {
+	prop := &SettingTransferScopeProperty{}
+	prop.Set(scope.Get())
+	return api_property.Property(prop)
+}
+
+// Property for formatted settings data
+type SettingDataProperty struct {
+	api_property.BytesArrayProperty
+}
+
+// Id for the Property
+func (data *SettingDataProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_DATA
+}
+
+// Label for the Property
+func (data *SettingDataProperty) Label() string {
+	return "Settings data"
+}
+
+// Description for the Property
+func (data *SettingDataProperty) Description() string {
+	return "Formatted settings data which was exported, or which should be imported."
+}
+
+// Is the Property internal only
+func (data *SettingDataProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (data *SettingDataProperty) Copy() api_property.Property {
+	prop := &SettingDataProperty{}
+	prop.Set(data.Get())
+	return api_property.Property(prop)
+}
+
+// Property which asks for an import to only report what it would change
+type SettingDryRunProperty struct {
+	api_property.BoolProperty
+}
+
+// Id for the Property
+func (dryRun *SettingDryRunProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_DRYRUN
+}
+
+// Label for the Property
+func (dryRun *SettingDryRunProperty) Label() string {
+	return "Dry run"
+}
+
+// Description for the Property
+func (dryRun *SettingDryRunProperty) Description() string {
+	return "Only report what would change, without saving any settings."
+}
+
+// Is the Property internal only
+func (dryRun *SettingDryRunProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (dryRun *SettingDryRunProperty) Copy() api_property.Property {
+	prop := &SettingDryRunProperty{}
+	prop.Set(dryRun.Get())
+	return api_property.Property(prop)
+}
+
+// Property which reports imported settings that conflicted with existing values
+type SettingConflictsProperty struct {
+	api_property.StringSliceProperty
+}
+
+// Id for the Property
+func (conflicts *SettingConflictsProperty) Id() string {
+	return OPERATION_PROPERTY_SETTING_CONFLICTS
+}
+
+// Label for the Property
+func (conflicts *SettingConflictsProperty) Label() string {
+	return "Setting conflicts"
+}
+
+// Description for the Property
+func (conflicts *SettingConflictsProperty) Description() string {
+	return "Imported settings which replace a different existing value in the target scope."
+}
+
+// Is the Property internal only
+func (conflicts *SettingConflictsProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (conflicts *SettingConflictsProperty) Copy() api_property.Property {
+	prop := &SettingConflictsProperty{}
+	prop.Set(conflicts.Get())
+	return api_property.Property(prop)
+}
@@ -2,7 +2,10 @@ package configwrapper
 
 import (
 	// "errors"
+	"context"
+	"sort"
 	"strings"
+	"sync"
 
 	log "github.com/Sirupsen/logrus"
 	"gopkg.in/yaml.v2"
@@ -20,29 +23,121 @@ func New_BaseSettingConfigWrapperYmlOperation(wrapper api_config.ConfigWrapper)
 	return &BaseSettingConfigWrapperYmlOperation{
 		wrapper:  wrapper,
 		settings: Settings{},
+		sources:  api_config.ConfigScopedValues{},
+		dirty:    []string{},
+		schema:   SettingsSchema{},
 	}
 }
 
 // A SettingsSource implementation for yml settings
+//
+// The wrapper is safe for concurrent use.  Loaded settings are replaced
+// as a whole on each load, and every load or change increments a
+// generation counter, so that consumers can tell when values changed.
 type BaseSettingConfigWrapperYmlOperation struct {
-	wrapper  api_config.ConfigWrapper // The config wrapper will be used to retrieve and save full config
-	settings Settings                 // the values map stores parsed values from config
+	wrapper    api_config.ConfigWrapper      // The config wrapper will be used to retrieve and save full config
+	settings   Settings                      // the values map stores parsed values from config
+	sources    api_config.ConfigScopedValues // the raw yml bytes for each scope, as they were loaded
+	dirty      []string                      // scopes that have been changed since the last save
+	schema     SettingsSchema                // the settings schema, used to identify secret settings
+	generation uint64                        // incremented whenever the settings are loaded or changed
+
+	context       context.Context      // when done, all subscriptions and watching end
+	subscriptions settingSubscriptions // handlers for setting change events
+	watching      bool                 // config is watched for changes while there are subscriptions
+
+	lock sync.RWMutex
 }
 
 // Retrieve values by parsing bytes from the wrapper
 func (setting *BaseSettingConfigWrapperYmlOperation) Load() error {
-	setting.settings = Settings{} // reset stored settings so that we can repopulate it.
+	setting.lock.Lock()
+	defer setting.lock.Unlock()
+	return setting.load()
+}
+
+// Discard any loaded values, and load them again from the wrapper
+//
+// Subscribers are sent an event for each scope value that changed.
+func (setting *BaseSettingConfigWrapperYmlOperation) Reload() error {
+	events, err := setting.reloadChanges()
+	setting.subscriptions.Publish(events)
+	return err
+}
+
+// Return the generation of the loaded settings, which changes on every load and set
+func (setting *BaseSettingConfigWrapperYmlOperation) Generation() uint64 {
+	setting.lock.RLock()
+	defer setting.lock.RUnlock()
+	return setting.generation
+}
+
+// Return a copy of all of the loaded settings, with the precedence and schema
+// that go with them, which will not change if the wrapper does
+func (setting *BaseSettingConfigWrapperYmlOperation) Snapshot() SettingsSnapshot {
+	setting.safe()
+	setting.lock.RLock()
+	defer setting.lock.RUnlock()
+	return setting.snapshot()
+}
+
+// Make a snapshot of the loaded settings (the caller must hold a lock)
+func (setting *BaseSettingConfigWrapperYmlOperation) snapshot() SettingsSnapshot {
+	return SettingsSnapshot{
+		Settings:   setting.settings.Copy(),
+		precedence: setting.precedence(),
+		schema:     setting.schema,
+	}
+}
+
+// Lazy load the settings, if they have not yet been loaded
+func (setting *BaseSettingConfigWrapperYmlOperation) safe() {
+	setting.lock.RLock()
+	empty := setting.settings.Empty()
+	setting.lock.RUnlock()
+
+	if empty {
+		setting.lock.Lock()
+		if setting.settings.Empty() { // another goroutine may have loaded while we waited
+			setting.load()
+		}
+		setting.lock.Unlock()
+	}
+}
+
+// Load the settings from the wrapper (the caller must hold the write lock)
+func (setting *BaseSettingConfigWrapperYmlOperation) load() error {
+	settings := Settings{} // build new settings, so that no earlier snapshot is changed
+	settings.safe()
+
+	if schema, err := LoadSettingsSchema(setting.wrapper); err == nil {
+		setting.schema = schema
+	} else {
+		log.WithError(err).Debug("No settings schema could be loaded")
+	}
+
 	if sources, err := setting.wrapper.Get(CONFIG_KEY_SETTINGS); err == nil {
 		for _, scope := range sources.Order() {
 			scopedSource, _ := sources.Get(scope)
 			scopedValues := map[string]string{} // temporarily hold all settings for a specific scope in this
 			if err := yaml.Unmarshal(scopedSource, &scopedValues); err == nil {
-				setting.settings.MergeScope(scope, scopedValues)
+				settings.MergeScope(scope, scopedValues)
 			} else {
-				log.WithError(err).WithFields(log.Fields{"scope": scope}).Error("Couldn't marshall yml scope")
+				// keep any valid keys, so that one bad value doesn't drop the whole scope
+				issues, validValues := lintSettingsScope(scope, settingScopeFileName(CONFIG_KEY_SETTINGS, scope), scopedSource, SettingsSchema{})
+				settings.MergeScope(scope, validValues)
+				for _, issue := range issues {
+					log.WithError(err).WithFields(log.Fields{"scope": scope, "key": issue.Key, "line": issue.Line}).Error("Couldn't marshall yml scope: " + issue.Message)
+				}
+				scopedValues = validValues
 			}
-			log.WithFields(log.Fields{"bytes": string(scopedSource), "values": scopedValues, "settings": setting}).Debug("Settings:Config->Load()")
+			log.WithFields(log.Fields{"scope": scope, "values": setting.maskedValues(scopedValues)}).Debug("Settings:Config->Load()")
 		}
+
+		setting.settings = settings
+		setting.sources = sources // keep the raw source so that saves can make minimal edits
+		setting.dirty = []string{}
+		setting.generation++
 		return nil
 	} else {
 		log.WithError(err).Error("Error loading config for " + CONFIG_KEY_SETTINGS)
@@ -50,39 +145,51 @@ func (setting *BaseSettingConfigWrapperYmlOperation) Load() error {
 	}
 }
 
-// Save the current values to the wrapper
+// Save the changed scopes to the wrapper
+//
+// Only scopes which have been changed by Set are written, and
+// each is written from its original source with only the changed
+// keys edited, so that comments, key order and formatting survive.
 func (setting *BaseSettingConfigWrapperYmlOperation) Save() error {
-	// create and initialize some primitve map for holding all settings by scope
-	configMap := map[string]map[string]string{} // map[scope]map[key]value
-	for _, scope := range setting.settings.Scopes() {
-		configMap[scope] = map[string]string{}
-	}
-
-	// Map all of the scoped values into the map
-	for _, key := range setting.settings.Keys() {
-		scopedValues, _ := setting.settings.Get(key)
-
-		for _, scope := range scopedValues.Scopes() {
-			scopedValue, _ := scopedValues.Get(scope)
+	setting.lock.Lock()
+	defer setting.lock.Unlock()
+	return setting.save()
+}
 
-			configMap[scope][key] = string(scopedValue)
-		}
+// Save the changed scopes to the wrapper (the caller must hold the write lock)
+func (setting *BaseSettingConfigWrapperYmlOperation) save() error {
+	if len(setting.dirty) == 0 {
+		return nil
 	}
+	if err := setting.saveSources(map[string][]byte{}); err != nil {
+		return err
+	}
+	setting.dirty = []string{}
+	return nil
+}
 
-	// convert the map to a ConfigScopedValues{} by marshalling the settings maps
+// Write the changed scopes, along with any edited sources that have not
+// yet replaced the loaded ones, to the wrapper (the caller must hold the
+// write lock).  Nothing in memory is changed.
+func (setting *BaseSettingConfigWrapperYmlOperation) saveSources(edited map[string][]byte) error {
 	scopedValues := api_config.ConfigScopedValues{}
-	for scope, values := range configMap {
-		if valuesYml, err := yaml.Marshal(values); err == nil {
-			scopedValues.Set(scope, api_config.ConfigScopedValue(valuesYml))
-		} else {
-			return err
+	for _, scope := range setting.dirty {
+		if _, found := edited[scope]; !found {
+			scopedSource, _ := setting.sources.Get(scope)
+			scopedValues.Set(scope, scopedSource)
 		}
 	}
+	scopes := []string{}
+	for scope := range edited {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	for _, scope := range scopes {
+		scopedValues.Set(scope, api_config.ConfigScopedValue(edited[scope]))
+	}
 
 	// Use the Config wrapper to save the scoped values
-	setting.wrapper.Set(CONFIG_KEY_SETTINGS, scopedValues)
-
-	return nil
+	return setting.wrapper.Set(CONFIG_KEY_SETTINGS, scopedValues)
 }
 
 // Return the default scope string for the wrapper
@@ -93,37 +200,164 @@ func (setting *BaseSettingConfigWrapperYmlOperation) DefaultScope() string {
 	return "project"
 }
 
+// Return the scope precedence chain used to resolve setting values
+//
+// A project can configure the chain using the SETTING_KEY_PRECEDENCE
+// setting, otherwise the default scope is preferred (after any profile
+// overlays of it, like project.stage), followed by the remaining scopes
+// in the order that the config wrapper provided them.
+func (setting *BaseSettingConfigWrapperYmlOperation) Precedence() []string {
+	setting.safe()
+	setting.lock.RLock()
+	defer setting.lock.RUnlock()
+	return setting.precedence()
+}
+
+// Build the scope precedence chain (the caller must hold a lock)
+func (setting *BaseSettingConfigWrapperYmlOperation) precedence() []string {
+	defaultScope := setting.DefaultScope()
+	defaultPrecedence := []string{}
+	for _, scope := range setting.sources.Order() {
+		if strings.HasPrefix(scope, defaultScope+".") {
+			defaultPrecedence = append(defaultPrecedence, scope)
+		}
+	}
+	defaultPrecedence = append(defaultPrecedence, defaultScope)
+	for _, scope := range setting.sources.Order() {
+		if scope != defaultScope && !strings.HasPrefix(scope, defaultScope+".") {
+			defaultPrecedence = append(defaultPrecedence, scope)
+		}
+	}
+
+	if values, found := setting.settings.Get(SETTING_KEY_PRECEDENCE); found {
+		if _, chain, found := values.Resolve(defaultPrecedence); found {
+			if precedence := ParseSettingPrecedence(string(chain)); len(precedence) > 0 {
+				return precedence
+			}
+		}
+	}
+	return defaultPrecedence
+}
+
+// Is a setting secret, in which case its value should be masked unless revealed
+func (setting *BaseSettingConfigWrapperYmlOperation) Secret(key string) bool {
+	setting.safe()
+	setting.lock.RLock()
+	defer setting.lock.RUnlock()
+	return setting.schema.Secret(key)
+}
+
+// Mask the secret values in a scope map, so that it can be logged
+func (setting *BaseSettingConfigWrapperYmlOperation) maskedValues(values map[string]string) map[string]string {
+	masked := map[string]string{}
+	for key, value := range values {
+		masked[key] = settingMaskValue(setting.schema.Secret(key), []byte(value))
+	}
+	return masked
+}
+
 // SettingSource interface List implementation
 func (setting *BaseSettingConfigWrapperYmlOperation) Get(key string) (SettingValues, bool) {
-	if setting.settings.Empty() {
-		setting.Load()
-	}
+	setting.safe()
+	setting.lock.RLock()
+	defer setting.lock.RUnlock()
+
 	value, found := setting.settings.Get(key)
 
-	log.WithFields(log.Fields{"key": key, "value": value, "found": found, "settings": setting}).Debug("Settings:Config->Get()")
-	return value, found
+	log.WithFields(log.Fields{"key": key, "scopes": value.Scopes(), "found": found, "secret": setting.schema.Secret(key)}).Debug("Settings:Config->Get()")
+	return value.Copy(), found
 }
 
 // SettingSource interface List implementation
+//
+// Subscribers are sent an event for each scope value that changed,
+// after the lock is released, so that handlers may use the wrapper.
 func (setting *BaseSettingConfigWrapperYmlOperation) Set(key string, values SettingValues) bool {
-	if setting.settings.Empty() {
-		setting.Load()
+	_, success := setting.SetAll(map[string]SettingValues{key: values})
+	return success
+}
+
+// Set a number of keys together, with a single save
+//
+// All of the yml sources are edited and saved before anything in
+// memory is changed, so if any value can't be set or saved then none
+// of them are.  The settings from just before the change are returned,
+// whether or not it succeeded.
+func (setting *BaseSettingConfigWrapperYmlOperation) SetAll(changes map[string]SettingValues) (SettingsSnapshot, bool) {
+	setting.safe()
+	before, success, events := setting.set(changes)
+	setting.subscriptions.Publish(events)
+	return before, success
+}
+
+// Set keys, returning the settings from before, and change events for any changed values
+func (setting *BaseSettingConfigWrapperYmlOperation) set(changes map[string]SettingValues) (SettingsSnapshot, bool, []SettingChangeEvent) {
+	setting.lock.Lock()
+	defer setting.lock.Unlock()
+
+	snapshot := setting.snapshot()
+
+	keys := []string{}
+	for key := range changes {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	setting.settings.Set(key, values)
-	if err := setting.Save(); err == nil {
-		return true
-	} else {
+	// edit copies of the sources, so that a failed edit leaves every scope as it was
+	edited := map[string][]byte{}
+	editedScopes := []string{}
+	for _, key := range keys {
+		values := changes[key]
+		for _, scope := range values.Scopes() {
+			source, found := edited[scope]
+			if !found {
+				scopedSource, _ := setting.sources.Get(scope)
+				source = []byte(scopedSource)
+				editedScopes = append(editedScopes, scope)
+			}
+
+			value, _ := values.Get(scope)
+			source, err := ymlTool_SetFlatValue(source, key, string(value))
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{"key": key, "scope": scope}).Error("Could not set setting, failed to edit yml source")
+				return snapshot, false, nil
+			}
+			edited[scope] = source
+		}
+	}
+
+	// build the changed settings as a copy, so that no earlier snapshot is changed
+	// (only the scopes passed are changed, any other scope values for a key are kept)
+	before := setting.settings
+	settings := setting.settings.Copy()
+	for _, key := range keys {
+		merged, _ := settings.Get(key)
+		merged = merged.Copy()
+		merged.Merge(changes[key], true)
+		settings.Set(key, merged)
+	}
+
+	// save before anything in memory changes, so that a failed save leaves the wrapper as it is on disk
+	if err := setting.saveSources(edited); err != nil {
 		log.WithError(err).Error("Could not set setting, Config wrapper failed to save")
-		return false
+		return snapshot, false, nil
+	}
+
+	for _, scope := range editedScopes {
+		setting.sources.Set(scope, api_config.ConfigScopedValue(edited[scope]))
 	}
+	setting.dirty = []string{}
+	setting.settings = settings
+	setting.generation++
+
+	return snapshot, true, settingsChanges(before, settings, SETTING_CHANGE_SOURCE_SET)
 }
 
 // SettingSource interface List implementation
 func (setting *BaseSettingConfigWrapperYmlOperation) List(parent string) []string {
-	if setting.settings.Empty() {
-		setting.Load()
-	}
+	setting.safe()
+	setting.lock.RLock()
+	defer setting.lock.RUnlock()
 
 	keys := []string{}
 	for _, key := range setting.settings.Keys() {
@@ -133,3 +367,62 @@ func (setting *BaseSettingConfigWrapperYmlOperation) List(parent string) []strin
 	}
 	return keys
 }
+
+// Check all settings scopes for problems, reading them fresh from the wrapper
+//
+// Keys nobody reads are those which match no reader registered with
+// RegisterSettingReader, and which no When condition in the project
+// components or authorization rules uses.
+func (setting *BaseSettingConfigWrapperYmlOperation) Lint() ([]SettingLintIssue, error) {
+	issues := []SettingLintIssue{}
+	file := CONFIG_KEY_SETTINGS + ".yml"
+
+	schema, err := LoadSettingsSchema(setting.wrapper)
+	if err != nil {
+		issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_WARNING, File: CONFIG_KEY_SETTINGS_SCHEMA + ".yml", Message: "settings schema could not be loaded: " + err.Error()})
+	}
+
+	sources, err := setting.wrapper.Get(CONFIG_KEY_SETTINGS)
+	if err != nil {
+		return issues, err
+	}
+
+	settings := Settings{}
+	for _, scope := range sources.Order() {
+		scopedSource, _ := sources.Get(scope)
+		scopeIssues, scopeValues := lintSettingsScope(scope, settingScopeFileName(CONFIG_KEY_SETTINGS, scope), scopedSource, schema)
+		issues = append(issues, scopeIssues...)
+		settings.MergeScope(scope, scopeValues)
+	}
+
+	conditionKeys := settingConditionKeys(setting.wrapper)
+
+	keys := settings.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		values, _ := settings.Get(key)
+
+		// keys nobody reads, as no reader is registered for them and no condition uses them
+		if !settingKeyIsRead(key, conditionKeys) {
+			issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_NOTICE, File: file, Key: key, Message: "key is not read by any registered reader or When condition"})
+		}
+
+		// keys with different values in different scopes
+		conflicting := []string{}
+		var firstValue []byte
+		for index, scope := range values.Scopes() {
+			value, _ := values.Get(scope)
+			if index == 0 {
+				firstValue = value
+			} else if string(value) != string(firstValue) {
+				conflicting = values.Scopes()
+				break
+			}
+		}
+		if len(conflicting) > 0 {
+			issues = append(issues, SettingLintIssue{Severity: SETTING_LINT_NOTICE, File: file, Key: key, Message: "key has different values in scopes " + strings.Join(conflicting, ", ")})
+		}
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,105 @@
+package configwrapper
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+/**
+ * Tools for making minimal edits to flat yml settings
+ * sources, so that comments, key order and formatting
+ * of hand maintained files survive a save.
+ */
+
+// Assign a single top level key in flat yml source, leaving all other lines untouched
+func ymlTool_SetFlatValue(source []byte, key string, value string) ([]byte, error) {
+	entry, err := yaml.Marshal(map[string]string{key: value})
+	if err != nil {
+		return source, err
+	}
+	entryLines := ymlTool_SplitLines(entry)
+	entryLines = entryLines[:len(entryLines)-1] // yaml.Marshal always ends with a newline
+
+	lines := ymlTool_SplitLines(source)
+	if start, end := ymlTool_FindFlatKey(lines, key); start >= 0 {
+		// replace the existing entry, including any continuation lines
+		edited := append([]string{}, lines[:start]...)
+		edited = append(edited, entryLines...)
+		lines = append(edited, lines[end:]...)
+	} else {
+		// append the entry, keeping a trailing newline at the end of the source
+		last := len(lines) - 1
+		if lines[last] == "" {
+			lines = append(lines[:last], append(entryLines, "")...)
+		} else {
+			lines = append(lines, append(entryLines, "")...)
+		}
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// Split yml source into lines (a trailing newline produces a last empty line)
+func ymlTool_SplitLines(source []byte) []string {
+	return strings.Split(string(source), "\n")
+}
+
+// Find the line range [start, end) of a top level key entry in yml source lines, or -1 if not found
+func ymlTool_FindFlatKey(lines []string, key string) (int, int) {
+	for _, entryLines := range ymlTool_FlatEntries(lines) {
+		start, end := entryLines[0], entryLines[1]
+		entry := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(strings.Join(lines[start:end], "\n")), &entry); err == nil {
+			if _, found := entry[key]; found {
+				return start, end
+			}
+		}
+	}
+	return -1, -1
+}
+
+// List the line ranges [start, end) of each top level entry in yml source lines
+func ymlTool_FlatEntries(lines []string) [][2]int {
+	entries := [][2]int{}
+	for start, line := range lines {
+		if !ymlTool_IsTopLevelLine(line) {
+			continue
+		}
+
+		// an entry continues through all following indented lines (blank lines are allowed inside)
+		end := start + 1
+		for next := start + 1; next < len(lines); next++ {
+			if strings.HasPrefix(lines[next], " ") || strings.HasPrefix(lines[next], "\t") {
+				end = next + 1
+			} else if strings.TrimSpace(lines[next]) != "" {
+				break
+			}
+		}
+		entries = append(entries, [2]int{start, end})
+	}
+	return entries
+}
+
+// Is a yml source line the start of a top level map entry
+func ymlTool_IsTopLevelLine(line string) bool {
+	if line == "" {
+		return false
+	}
+	switch line[0] {
+	case ' ', '\t', '#', '-':
+		return false
+	}
+	return true
+}
+
+// Set a top level key in an ordered yml document, replacing it in place or appending it
+func ymlTool_SetMapSliceValue(document yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for index, item := range document {
+		if item.Key == key {
+			document[index].Value = value
+			return document
+		}
+	}
+	return append(document, yaml.MapItem{Key: key, Value: value})
+}
@@ -1,6 +1,8 @@
 package local
 
 import (
+	"sync"
+
 	api_config "github.com/wunderkraut/radi-api/operation/config"
 	api_setting "github.com/wunderkraut/radi-api/operation/setting"
 	api_result "github.com/wunderkraut/radi-api/result"
@@ -16,12 +18,20 @@ import (
 func New_LocalHandler_Base(settings *LocalAPISettings) *LocalHandler_Base {
 	return &LocalHandler_Base{
 		settings: settings,
+		profile:  &localProfile{},
 	}
 }
 
 // A handler for base local handlers
 type LocalHandler_Base struct {
 	settings *LocalAPISettings
+	profile  *localProfile // shared by copies of the base, so that the profile is only resolved once
+}
+
+// The active profile, resolved once
+type localProfile struct {
+	once    sync.Once
+	profile string
 }
 
 // Validate the handler
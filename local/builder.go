@@ -1,6 +1,9 @@
 package local
 
 import (
+	"errors"
+	"strings"
+
 	log "github.com/Sirupsen/logrus"
 
 	api_api "github.com/wunderkraut/radi-api/api"
@@ -12,6 +15,8 @@ import (
 	api_config "github.com/wunderkraut/radi-api/operation/config"
 	api_security "github.com/wunderkraut/radi-api/operation/security"
 	api_setting "github.com/wunderkraut/radi-api/operation/setting"
+
+	handler_configwrapper "github.com/wunderkraut/radi-handlers/configwrapper"
 )
 
 /**
@@ -63,9 +68,75 @@ func (builder *LocalBuilder) AddHandler(hand api_handler.Handler) {
 	builder.handlers.Add(hand)
 }
 
+// Publish the schema for local project component Settings
+//
+// Local components take no settings.  The profile can't be one, as the
+// components are read from the profile's project files, so it is only
+// taken from LocalAPISettings, RADI_PROFILE or the base settings files.
+func (builder *LocalBuilder) ProjectSettingsSchema() handler_configwrapper.ProjectSettingsSchema {
+	return handler_configwrapper.ProjectSettingsSchema{}
+}
+
+// The handlers that each local implementation adds
+var localBuilderHandlers = map[string][]string{
+	"config":   []string{"local.config"},
+	"setting":  []string{"local.setting"},
+	"project":  []string{"local.project"},
+	"security": []string{"local.security"},
+}
+
+// List the implementations that the local builder provides
+func (builder *LocalBuilder) KnownImplementations() []string {
+	return []string{"config", "setting", "project", "security"}
+}
+
+// List the handlers that an implementation adds
+func (builder *LocalBuilder) ImplementationHandlers(implementation string) []string {
+	return localBuilderHandlers[implementation]
+}
+
+// What each local implementation requires from other implementations
+var localBuilderRequires = map[string][]string{
+	"setting":  []string{"config"},
+	"security": []string{"config"},
+}
+
+// List what an implementation requires from other implementations, so
+// that project components can be ordered by it
+func (builder *LocalBuilder) ImplementationRequires(implementation string) []string {
+	return localBuilderRequires[implementation]
+}
+
 // Initialize the handler for certain implementations
+//
+// Implementations are activated in dependency order, so that for
+// example config is built before setting, which reads builder.Config.
 func (builder *LocalBuilder) Activate(implementations api_builder.Implementations, settingsProvider api_builder.SettingsProvider) api_result.Result {
+	available := []string{}
+	if builder.Config != nil {
+		available = append(available, "config") // config from an earlier activation
+	}
+
+	dependencies := []handler_configwrapper.ProjectDependency{}
 	for _, implementation := range implementations.Order() {
+		dependencies = append(dependencies, handler_configwrapper.ProjectDependency{
+			Id:       implementation,
+			Requires: localBuilderRequires[implementation],
+			Provides: []string{implementation},
+		})
+	}
+
+	ordered, err := handler_configwrapper.SortProjectDependencies(dependencies, available)
+	if err != nil {
+		log.WithError(err).Error("Local builder could not order its implementations")
+		res := api_result.New_StandardResult()
+		res.MarkFailed()
+		res.AddError(err)
+		res.MarkFinished()
+		return res.Result()
+	}
+
+	for _, implementation := range ordered {
 		switch implementation {
 		case "config":
 			builder.build_Config()
@@ -84,6 +155,21 @@ func (builder *LocalBuilder) Activate(implementations api_builder.Implementation
 	return api_result.MakeSuccessfulResult()
 }
 
+// Activate implementations in a fresh builder, and list the operations that
+// they add, for planning
+//
+// Local activation only builds handlers and their wrappers in memory,
+// and reads config, so it has no side effects.
+func (builder *LocalBuilder) DryActivate(implementations api_builder.Implementations, settingsProvider api_builder.SettingsProvider) ([]string, error) {
+	dryRun := New_LocalBuilder(builder.settings)
+	res := dryRun.Activate(implementations, settingsProvider)
+	<-res.Finished()
+	if !res.Success() {
+		return dryRun.Operations().Order(), errors.New("Local builder could not activate " + strings.Join(implementations.Order(), ", "))
+	}
+	return dryRun.Operations().Order(), nil
+}
+
 // Validate the builder after Activation is complete
 func (builder *LocalBuilder) Validate() api_result.Result {
 	return api_result.MakeSuccessfulResult()
@@ -161,6 +247,7 @@ func (builder *LocalBuilder) build_Project() api_result.Result {
 	local_project := LocalHandler_Project{
 		LocalHandler_Base: *builder.Base(),
 	}
+	local_project.SetConfigWrapper(builder.Config) // nil if config was not activated, which disables planning
 
 	res := local_project.Validate()
 	<-res.Finished()
@@ -174,6 +261,20 @@ func (builder *LocalBuilder) build_Project() api_result.Result {
 	return res
 }
 
+// Get the project components for the API to activate, with the local
+// builder schemas registered and conditions evaluated against the local
+// config, or nil if config has not been activated
+func (builder *LocalBuilder) ProjectComponents() api_builder.ProjectConfigWrapper {
+	if builder.Config == nil {
+		return nil
+	}
+	local_project := LocalHandler_Project{
+		LocalHandler_Base: *builder.Base(),
+	}
+	local_project.SetConfigWrapper(builder.Config)
+	return local_project.ProjectComponentsConfigWrapper().ProjectConfigWrapper()
+}
+
 // Add local Handlers for Security operations
 func (builder *LocalBuilder) build_Security() api_result.Result {
 	// Build a command Handler
@@ -181,6 +282,7 @@ func (builder *LocalBuilder) build_Security() api_result.Result {
 		LocalHandler_Base: *builder.Base(),
 	}
 	local_security.SetConfigWrapper(builder.Config)
+	local_security.SetOperations(builder.Operations)
 
 	res := local_security.Validate()
 	<-res.Finished()
@@ -1,10 +1,24 @@
 package local
 
 import (
+	"io/ioutil"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
 	api_operation "github.com/wunderkraut/radi-api/operation"
 	api_config "github.com/wunderkraut/radi-api/operation/config"
 
 	handler_bytesource "github.com/wunderkraut/radi-handlers/bytesource"
+	handler_configwrapper "github.com/wunderkraut/radi-handlers/configwrapper"
+)
+
+const (
+	// Environment variable which can select the active profile
+	LOCAL_PROFILE_ENV = "RADI_PROFILE"
+	// Setting key which can select the active profile
+	LOCAL_PROFILE_SETTING = "profile"
 )
 
 // A handler for local config
@@ -22,7 +36,7 @@ func (handler *LocalHandler_Config) Operations() api_operation.Operations {
 	ops := api_operation.New_SimpleOperations()
 
 	// build a ConfigConnector for use with the Config operations.
-	connector := handler_bytesource.New_ConfigConnectYmlFiles(handler.LocalAPISettings().ConfigPaths)
+	connector := handler_bytesource.New_ConfigConnectYmlFilesProfile(handler.LocalAPISettings().ConfigPaths, handler.Profile())
 
 	// Build this base operation to be shared across all of our config operations
 	baseConnectorOperation := api_config.New_BaseConfigConnectorOperation(connector)
@@ -39,3 +53,56 @@ func (handler *LocalHandler_Config) Operations() api_operation.Operations {
 func (handler *LocalHandler_Config) ConfigWrapper() api_config.ConfigWrapper {
 	return api_config.ConfigWrapper(api_config.New_SimpleConfigWrapper(handler.Operations()))
 }
+
+// Determine the active profile
+//
+// The profile is taken from the API settings if it was set there,
+// then from the RADI_PROFILE environment variable, and finally from
+// a "profile" setting in the base settings files.  It is resolved
+// once for each base, and kept, so that the settings files are not
+// read again for each set of operations.
+func (handler *LocalHandler_Config) Profile() string {
+	if handler.profile == nil {
+		return handler.resolveProfile()
+	}
+	handler.profile.once.Do(func() {
+		handler.profile.profile = handler.resolveProfile()
+	})
+	return handler.profile.profile
+}
+
+// Resolve the active profile from the settings, environment and settings files
+func (handler *LocalHandler_Config) resolveProfile() string {
+	if profile := handler.LocalAPISettings().Profile; profile != "" {
+		return profile
+	}
+	if profile := os.Getenv(LOCAL_PROFILE_ENV); profile != "" {
+		return profile
+	}
+
+	// profile overlays can't select a profile, so only read the base settings files
+	baseConnector := handler_bytesource.New_ConfigConnectYmlFiles(handler.LocalAPISettings().ConfigPaths)
+	readers := baseConnector.Readers(handler_configwrapper.CONFIG_KEY_SETTINGS)
+	for _, scope := range readers.Order() {
+		reader, _ := readers.Get(scope)
+		source, err := ioutil.ReadAll(reader)
+		if err != nil {
+			continue
+		}
+
+		values := map[string]string{}
+		if err := yaml.Unmarshal(source, &values); err == nil {
+			if profile, found := values[LOCAL_PROFILE_SETTING]; found && profile != "" {
+				log.WithFields(log.Fields{"profile": profile, "scope": scope}).Debug("Using profile from settings")
+				return profile
+			}
+		}
+	}
+	return ""
+}
+
+// Make a context for evaluating config conditions, such as project component When conditions
+func (handler *LocalHandler_Config) ConditionContext() handler_configwrapper.ConditionContext {
+	settings := handler_configwrapper.New_BaseSettingConfigWrapperYmlOperation(handler.ConfigWrapper())
+	return handler_configwrapper.New_StandardConditionContext(handler.Profile(), handler.LocalAPISettings().ProjectRootPath, settings).ConditionContext()
+}
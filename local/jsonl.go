@@ -0,0 +1,146 @@
+package local
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+)
+
+/**
+ * Small tools for local append-only logs, kept as files
+ * with one JSON record per line.
+ */
+
+// Locks for each JSON lines file path, shared by all jsonlFile values for a path
+var (
+	jsonlLocks     = map[string]*sync.Mutex{}
+	jsonlLocksLock sync.Mutex
+)
+
+// A JSON lines file
+//
+// If maxSize is set, then the file is rotated before an append would
+// make it larger, keeping up to keep earlier files as path.1 (newest)
+// to path.keep (oldest).
+type jsonlFile struct {
+	path    string
+	maxSize int64
+	keep    int
+}
+
+// Get the lock for the file path, so that appends and reads through
+// different jsonlFile values for the same file are serialised
+func (file *jsonlFile) lock() *sync.Mutex {
+	jsonlLocksLock.Lock()
+	defer jsonlLocksLock.Unlock()
+
+	lock, found := jsonlLocks[file.path]
+	if !found {
+		lock = &sync.Mutex{}
+		jsonlLocks[file.path] = lock
+	}
+	return lock
+}
+
+// Append a record as a single line
+func (file *jsonlFile) Append(record interface{}) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	lock := file.lock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := os.MkdirAll(path.Dir(file.path), 0755); err != nil {
+		return err
+	}
+	if err := file.rotate(int64(len(line) + 1)); err != nil {
+		return err
+	}
+	osFile, err := os.OpenFile(file.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer osFile.Close()
+
+	_, err = osFile.Write(append(line, '\n'))
+	return err
+}
+
+// Rotate the file if appending some bytes would make it larger than the max size
+func (file *jsonlFile) rotate(appending int64) error {
+	if file.maxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(file.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Size()+appending <= file.maxSize {
+		return nil
+	}
+
+	if file.keep <= 0 {
+		return os.Remove(file.path)
+	}
+	os.Remove(file.rotatedPath(file.keep))
+	for index := file.keep - 1; index > 0; index-- {
+		if err := os.Rename(file.rotatedPath(index), file.rotatedPath(index+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(file.path, file.rotatedPath(1))
+}
+
+// The path of a rotated file, where 1 is the newest
+func (file *jsonlFile) rotatedPath(index int) string {
+	return file.path + "." + strconv.Itoa(index)
+}
+
+// Read all lines, oldest first, passing each to a handler (a missing file has no lines)
+//
+// Rotated files are read before the current file.
+func (file *jsonlFile) Each(handle func(line []byte) error) error {
+	lock := file.lock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	if file.maxSize > 0 {
+		for index := file.keep; index > 0; index-- {
+			if err := file.eachIn(file.rotatedPath(index), handle); err != nil {
+				return err
+			}
+		}
+	}
+	return file.eachIn(file.path, handle)
+}
+
+// Read all lines of a single file, passing each to a handler
+func (file *jsonlFile) eachIn(filePath string, handle func(line []byte) error) error {
+	osFile, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer osFile.Close()
+
+	scanner := bufio.NewScanner(osFile)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := handle(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -8,6 +8,7 @@ import (
 
 	jn_init "github.com/james-nesbitt/init-go"
 
+	api_builder "github.com/wunderkraut/radi-api/builder"
 	api_operation "github.com/wunderkraut/radi-api/operation"
 	api_property "github.com/wunderkraut/radi-api/property"
 	api_result "github.com/wunderkraut/radi-api/result"
@@ -15,6 +16,8 @@ import (
 
 	api_project "github.com/wunderkraut/radi-api/operation/project"
 	handler_bytesource "github.com/wunderkraut/radi-handlers/bytesource"
+	handler_configwrapper "github.com/wunderkraut/radi-handlers/configwrapper"
+	handler_null "github.com/wunderkraut/radi-handlers/null"
 )
 
 const (
@@ -31,6 +34,7 @@ const (
 // A handler for local project handler
 type LocalHandler_Project struct {
 	LocalHandler_Base
+	LocalHandler_ConfigWrapperBase
 }
 
 // [Handler.]Id returns a string ID for the handler
@@ -49,9 +53,44 @@ func (handler *LocalHandler_Project) Operations() api_operation.Operations {
 	ops.Add(api_operation.Operation(&LocalProjectCreateOperation{fileSettings: byteSourceFileSettings}))
 	ops.Add(api_operation.Operation(&LocalProjectGenerateOperation{fileSettings: byteSourceFileSettings}))
 
+	// Planning needs project config, which a project that doesn't exist yet has none of
+	if configWrapper := handler.ConfigWrapper(); configWrapper != nil {
+		ops.Add(api_operation.Operation(&handler_configwrapper.ProjectPlanOperation{
+			Components: handler.ProjectComponentsConfigWrapper(),
+			Config:     configWrapper,
+			Factories:  handler.BuilderFactories(),
+		}))
+	}
+
 	return ops.Operations()
 }
 
+// Make a project components wrapper, which evaluates conditions using the local config
+//
+// The schemas and requirements of the known builders are registered
+// before any components are loaded, so that component settings are
+// checked, and components ordered, on every load.
+func (handler *LocalHandler_Project) ProjectComponentsConfigWrapper() *handler_configwrapper.ProjectComponentsConfigWrapperYaml {
+	components := handler_configwrapper.New_ProjectComponentsConfigWrapperYaml(handler.ConfigWrapper())
+	for builderType, factory := range handler.BuilderFactories() {
+		builder := factory()
+		components.RegisterBuilderSchema(builderType, builder)
+		components.RegisterBuilderRequires(builderType, builder)
+	}
+	localConfig := LocalHandler_Config{LocalHandler_Base: handler.LocalHandler_Base}
+	components.SetConditionContext(localConfig.ConditionContext())
+	return components
+}
+
+// Factories for the builders that this package knows about, used for planning
+func (handler *LocalHandler_Project) BuilderFactories() map[string]handler_configwrapper.ProjectBuilderFactory {
+	settings := *handler.LocalAPISettings()
+	return map[string]handler_configwrapper.ProjectBuilderFactory{
+		"local": func() api_builder.Builder { return New_LocalBuilder(settings).Builder() },
+		"null":  func() api_builder.Builder { return handler_null.New_NullBuilder() },
+	}
+}
+
 /**
  * Operation to initialize the current project as a radi project
  */
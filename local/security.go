@@ -30,6 +30,13 @@ import (
 type LocalHandler_Security struct {
 	LocalHandler_Base
 	LocalHandler_ConfigWrapperBase
+
+	operations func() api_operation.Operations // all built operations, so that explain can use their property types
+}
+
+// Set how to get all of the built operations, which security.explain uses to type property values
+func (handler *LocalHandler_Security) SetOperations(operations func() api_operation.Operations) {
+	handler.operations = operations
 }
 
 // Identify the handler
@@ -42,12 +49,36 @@ func (handler *LocalHandler_Security) Operations() api_operation.Operations {
 	ops := api_operation.New_SimpleOperations()
 
 	// Make a SecurityWrapper Base operation
-	securityWrapper := handler_configwrapper.New_SecurityConfigWrapperYml(handler.ConfigWrapper()).SecurityConfigWrapper()
+	ymlWrapper := handler_configwrapper.New_SecurityConfigWrapperYml(handler.ConfigWrapper())
+	securityWrapper := ymlWrapper.SecurityConfigWrapper()
+	ymlWrapper.SetUserSource(New_LocalUserSource(handler.LocalHandler_Base.LocalAPISettings(), securityWrapper))
+	localConfig := LocalHandler_Config{LocalHandler_Base: handler.LocalHandler_Base}
+	ymlWrapper.SetConditionContext(localConfig.ConditionContext())
 	base := handler_configwrapper.New_SecurityWrapperBaseOperation(securityWrapper)
+	ruleBase := handler_configwrapper.SecurityRuleBaseOperation{Wrapper: ymlWrapper}
 
 	// Add operations from using the base
 	ops.Add(api_operation.Operation(New_LocalCurrentUserOperation(handler.LocalHandler_Base.LocalAPISettings(), base)))
-	ops.Add(api_operation.Operation(&handler_configwrapper.SecurityConfigWrapperAuthorizeOperation{SecurityWrapperBaseOperation: *base}))
+	// Password authentication needs a user config path, for the credentials and session
+	if sessions := New_LocalSessionStore(handler.LocalHandler_Base.LocalAPISettings(), ymlWrapper); sessions != nil {
+		ops.Add(api_operation.Operation(&LocalAuthenticateOperation{Authenticator: ymlWrapper, Sessions: sessions}))
+	}
+	// Authorization decisions are audited if there is a user config path
+	var audit handler_configwrapper.SecurityAuditLog
+	if localAudit := New_LocalSecurityAuditLog(handler.LocalHandler_Base.LocalAPISettings()); localAudit != nil {
+		audit = localAudit.SecurityAuditLog()
+	}
+
+	ops.Add(api_operation.Operation(&handler_configwrapper.SecurityConfigWrapperAuthorizeOperation{SecurityWrapperBaseOperation: *base, Audit: audit}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SecurityExplainOperation{SecurityWrapperBaseOperation: *base, Operations: handler.operations}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SecurityAuditOperation{Audit: audit}))
+
+	// Rule management operations, which edit authorize.yml
+	ops.Add(api_operation.Operation(&handler_configwrapper.SecurityRuleListOperation{SecurityRuleBaseOperation: ruleBase}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SecurityRuleAddOperation{SecurityRuleBaseOperation: ruleBase}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SecurityRuleRemoveOperation{SecurityRuleBaseOperation: ruleBase}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SecurityRuleMoveOperation{SecurityRuleBaseOperation: ruleBase}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SecurityRuleLintOperation{SecurityRuleBaseOperation: ruleBase}))
 
 	return ops.Operations()
 }
@@ -78,21 +109,69 @@ func New_LocalCurrentUserOperation(settings *LocalAPISettings, base *handler_con
 	}
 	return &LocalCurrentUserOperation{
 		SecurityConfigWrapperUserOperation: configWrapperUserOperation,
-		settings: settings,
+		settings:                           settings,
 	}
 }
 
 func (userOp *LocalCurrentUserOperation) Exec(props api_property.Properties) api_result.Result {
 	result := api_result.New_StandardResult()
-	securityWrapper := userOp.SecurityConfigWrapper()
 
 	userProp, _ := props.Get(api_security.SECURITY_USER_PROPERTY_KEY)
 
-	currentUser := securityWrapper.CurrentUser()
+	currentUser := New_LocalUserSource(userOp.settings, userOp.SecurityConfigWrapper()).CurrentUser()
+
+	userProp.Set(currentUser)
+	result.MarkSuccess()
+
+	result.MarkFinished()
+
+	return api_result.Result(result)
+}
+
+func (userOp *LocalCurrentUserOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+/**
+ * A source for the local current user, which uses an
+ * authenticated session if there is one, then the
+ * security config wrapper user if there is one, and
+ * falls back to the OS user.
+ */
+
+// Constructor for LocalUserSource
+//
+// Sessions are only used if the security wrapper can reload users from
+// their credentials.
+func New_LocalUserSource(settings *LocalAPISettings, securityWrapper handler_configwrapper.SecurityConfigWrapper) *LocalUserSource {
+	users, _ := securityWrapper.(handler_configwrapper.SecurityCredentialUsers)
+	return &LocalUserSource{
+		settings:        settings,
+		securityWrapper: securityWrapper,
+		sessions:        New_LocalSessionStore(settings, users),
+	}
+}
+
+// A local current user source
+type LocalUserSource struct {
+	settings        *LocalAPISettings
+	securityWrapper handler_configwrapper.SecurityConfigWrapper
+	sessions        *LocalSessionStore
+}
+
+// Get the current user
+func (source *LocalUserSource) CurrentUser() api_security.SecurityUser {
+	if source.sessions != nil {
+		if session, currentUser, found := source.sessions.Current(); found {
+			log.WithFields(log.Fields{"id": currentUser.Id(), "label": currentUser.Label(), "expires": session.Expires}).Debug("Retrieved current user from authenticated session")
+			return currentUser
+		}
+	}
+
+	currentUser := source.securityWrapper.CurrentUser()
 
 	if currentUser == nil || currentUser.Id() == "anonymous" {
-		settings := userOp.settings
-		localUser := &settings.User
+		localUser := &source.settings.User
 		if localUser != nil {
 			currentUser = api_security.New_CoreUserSecurityUser(localUser).SecurityUser()
 			log.WithFields(log.Fields{"id": currentUser.Id(), "label": currentUser.Label()}).Debug("Retrieved current user from OS user default")
@@ -103,14 +182,5 @@ func (userOp *LocalCurrentUserOperation) Exec(props api_property.Properties) api
 		log.WithFields(log.Fields{"id": currentUser.Id(), "label": currentUser.Label()}).Debug("Retrieved current user from config")
 	}
 
-	userProp.Set(currentUser)
-	result.MarkSuccess()
-
-	result.MarkFinished()
-
-	return api_result.Result(result)
-}
-
-func (userOp *LocalCurrentUserOperation) Usage() api_usage.Usage {
-	return api_operation.Usage_External()
+	return currentUser
 }
@@ -0,0 +1,69 @@
+package local
+
+import (
+	"encoding/json"
+	"path"
+
+	handler_configwrapper "github.com/wunderkraut/radi-handlers/configwrapper"
+)
+
+const (
+	// File name for the authorization audit log, in the user config path
+	LOCAL_SECURITY_AUDIT_FILE = "security-audit.jsonl"
+	// Size in bytes at which the authorization audit log is rotated
+	LOCAL_SECURITY_AUDIT_MAX_SIZE = 5 * 1024 * 1024
+	// How many rotated authorization audit logs are kept
+	LOCAL_SECURITY_AUDIT_KEEP = 3
+)
+
+/**
+ * An authorization audit log, kept as a rotating local
+ * JSON lines file in the user config path.
+ */
+
+// Constructor for LocalSecurityAuditLog, which returns nil if there is no user config path
+func New_LocalSecurityAuditLog(settings *LocalAPISettings) *LocalSecurityAuditLog {
+	if settings.ConfigPaths == nil {
+		return nil
+	}
+	userPath, found := settings.ConfigPaths.Get(LOCAL_LOG_PATH_SCOPE)
+	if !found {
+		return nil
+	}
+	return &LocalSecurityAuditLog{
+		file: jsonlFile{
+			path:    path.Join(userPath.PathString(), LOCAL_SECURITY_AUDIT_FILE),
+			maxSize: LOCAL_SECURITY_AUDIT_MAX_SIZE,
+			keep:    LOCAL_SECURITY_AUDIT_KEEP,
+		},
+	}
+}
+
+// An authorization audit log in a local JSON lines file
+type LocalSecurityAuditLog struct {
+	file jsonlFile
+}
+
+// Convert this to a SecurityAuditLog
+func (audit *LocalSecurityAuditLog) SecurityAuditLog() handler_configwrapper.SecurityAuditLog {
+	return handler_configwrapper.SecurityAuditLog(audit)
+}
+
+// Append a record to the log
+func (audit *LocalSecurityAuditLog) Append(record handler_configwrapper.SecurityAuditRecord) error {
+	return audit.file.Append(record)
+}
+
+// Read all records from the log, including rotated logs
+func (audit *LocalSecurityAuditLog) Records() ([]handler_configwrapper.SecurityAuditRecord, error) {
+	records := []handler_configwrapper.SecurityAuditRecord{}
+	err := audit.file.Each(func(line []byte) error {
+		record := handler_configwrapper.SecurityAuditRecord{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
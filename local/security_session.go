@@ -0,0 +1,412 @@
+package local
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	api_operation "github.com/wunderkraut/radi-api/operation"
+	api_security "github.com/wunderkraut/radi-api/operation/security"
+	api_property "github.com/wunderkraut/radi-api/property"
+	api_result "github.com/wunderkraut/radi-api/result"
+	api_usage "github.com/wunderkraut/radi-api/usage"
+
+	handler_configwrapper "github.com/wunderkraut/radi-handlers/configwrapper"
+)
+
+const (
+	// File name for the authenticated session, in the user config path
+	LOCAL_SECURITY_SESSION_FILE = "session.json"
+	// How long an authenticated session lasts
+	LOCAL_SECURITY_SESSION_TTL = 12 * time.Hour
+	// Environment variable which holds the token of the authenticated session
+	LOCAL_SECURITY_SESSION_TOKEN_ENV = "RADI_SESSION_TOKEN"
+
+	LOCAL_SECURITY_AUTHENTICATE_USER_PROPERTY     = "local.security.authenticate.user"
+	LOCAL_SECURITY_AUTHENTICATE_PASSWORD_PROPERTY = "local.security.authenticate.password"
+	LOCAL_SECURITY_AUTHENTICATE_TOKEN_PROPERTY    = "local.security.authenticate.token"
+	LOCAL_SECURITY_AUTHENTICATE_EXPIRES_PROPERTY  = "local.security.authenticate.expires"
+)
+
+/**
+ * Local authenticated sessions, which are made by the
+ * authenticate operation and kept as a file in the user
+ * config path until they expire.
+ *
+ * Only a hash of the session token is kept, and a session
+ * is only used if the token is given in RADI_SESSION_TOKEN.
+ * The session user is reloaded from the credentials each
+ * time, so that removed users and changed groups and roles
+ * apply to existing sessions.
+ */
+
+// Constructor for LocalSessionStore, which returns nil if there is no user config path
+func New_LocalSessionStore(settings *LocalAPISettings, users handler_configwrapper.SecurityCredentialUsers) *LocalSessionStore {
+	if settings.ConfigPaths == nil {
+		return nil
+	}
+	userPath, found := settings.ConfigPaths.Get(LOCAL_LOG_PATH_SCOPE)
+	if !found {
+		return nil
+	}
+	return &LocalSessionStore{
+		path:  path.Join(userPath.PathString(), LOCAL_SECURITY_SESSION_FILE),
+		users: users,
+	}
+}
+
+// A store for a single local session
+type LocalSessionStore struct {
+	path  string
+	users handler_configwrapper.SecurityCredentialUsers // used to reload the session user, sessions are never used without it
+}
+
+// An authenticated session
+type LocalSession struct {
+	Token     string    `json:"-"` // the session token, which is only known when the session is made
+	TokenHash string    `json:"token_hash"`
+	Expires   time.Time `json:"expires"`
+	User      string    `json:"user"`
+}
+
+// Make a new session for an authenticated user, with a random token
+func New_LocalSession(user api_security.SecurityUser, ttl time.Duration) (LocalSession, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return LocalSession{}, err
+	}
+
+	session := LocalSession{
+		Token:   hex.EncodeToString(token),
+		Expires: time.Now().Add(ttl),
+		User:    user.Id(),
+	}
+	session.TokenHash = localSessionTokenHash(session.Token)
+	return session, nil
+}
+
+// Hash a session token, for keeping in the session file
+func localSessionTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Has the session expired
+func (session LocalSession) Expired() bool {
+	return !time.Now().Before(session.Expires)
+}
+
+// Does a token match the session token hash
+func (session LocalSession) Matches(token string) bool {
+	if token == "" || session.TokenHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(localSessionTokenHash(token)), []byte(session.TokenHash)) == 1
+}
+
+// Save a session, replacing any earlier session
+func (store *LocalSessionStore) Save(session LocalSession) error {
+	contents, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(store.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(store.path, contents, 0600)
+}
+
+// Get the current session and its user, reloaded from the credentials
+//
+// There is only a current session if it has not expired, its token is
+// given in RADI_SESSION_TOKEN, and its user still has credentials.
+func (store *LocalSessionStore) Current() (LocalSession, api_security.SecurityUser, bool) {
+	session := LocalSession{}
+
+	token := os.Getenv(LOCAL_SECURITY_SESSION_TOKEN_ENV)
+	if token == "" {
+		return session, nil, false
+	}
+
+	contents, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return session, nil, false
+	} else if err != nil {
+		log.WithError(err).Warn("Could not read the local session")
+		return session, nil, false
+	}
+	if err := json.Unmarshal(contents, &session); err != nil {
+		log.WithError(err).Warn("Could not parse the local session")
+		return session, nil, false
+	}
+	if session.User == "" || session.Expired() {
+		return session, nil, false
+	}
+	if !session.Matches(token) {
+		log.WithFields(log.Fields{"user": session.User}).Warn("Ignoring the local session, as the " + LOCAL_SECURITY_SESSION_TOKEN_ENV + " token does not match")
+		return session, nil, false
+	}
+	if store.users == nil {
+		log.WithFields(log.Fields{"user": session.User}).Warn("Ignoring the local session, as there are no credentials to reload the user from")
+		return session, nil, false
+	}
+	user, err := store.users.CredentialUser(session.User)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"user": session.User}).Warn("Ignoring the local session, as its user could not be reloaded")
+		return session, nil, false
+	}
+	return session, user, true
+}
+
+// Remove the current session
+func (store *LocalSessionStore) Clear() error {
+	if err := os.Remove(store.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+/**
+ * Local authenticate operation
+ */
+
+// Authenticate a user by password, and start a local session
+type LocalAuthenticateOperation struct {
+	api_security.BaseSecurityAuthenticateOperation
+
+	Authenticator handler_configwrapper.SecurityAuthenticator
+	Sessions      *LocalSessionStore
+}
+
+// Label the operation
+func (authenticate *LocalAuthenticateOperation) Label() string {
+	return "Authenticate"
+}
+
+// Description for the operation
+func (authenticate *LocalAuthenticateOperation) Description() string {
+	return "Authenticate with a user id and password, and start a local session."
+}
+
+// Help text for the operation
+func (authenticate *LocalAuthenticateOperation) Help() string {
+	return "Passwords are checked against the bcrypt or argon2id hashes in credentials.yml in the user config path.  The session lasts for 12 hours, and its user is the current user while the session token is set in the " + LOCAL_SECURITY_SESSION_TOKEN_ENV + " environment variable."
+}
+
+// Usage for the operation
+func (authenticate *LocalAuthenticateOperation) Usage() api_usage.Usage {
+	return api_operation.Usage_External()
+}
+
+// Validate the operation
+func (authenticate *LocalAuthenticateOperation) Validate() api_result.Result {
+	return api_result.MakeSuccessfulResult()
+}
+
+// Get properties
+func (authenticate *LocalAuthenticateOperation) Properties() api_property.Properties {
+	props := api_property.New_SimplePropertiesEmpty()
+
+	props.Add(api_property.Property(&LocalAuthenticateUserProperty{}))
+	props.Add(api_property.Property(&LocalAuthenticatePasswordProperty{}))
+	props.Add(api_property.Property(&LocalAuthenticateTokenProperty{}))
+	props.Add(api_property.Property(&LocalAuthenticateExpiresProperty{}))
+
+	return props.Properties()
+}
+
+// Execute the operation
+func (authenticate *LocalAuthenticateOperation) Exec(props api_property.Properties) api_result.Result {
+	res := api_result.New_StandardResult()
+
+	if err := authenticate.authenticate(props); err == nil {
+		res.MarkSuccess()
+	} else {
+		res.MarkFailed()
+		res.AddError(err)
+	}
+	res.MarkFinished()
+
+	return res.Result()
+}
+
+// Authenticate from the operation properties, and save the session
+func (authenticate *LocalAuthenticateOperation) authenticate(props api_property.Properties) error {
+	if authenticate.Authenticator == nil || authenticate.Sessions == nil {
+		return errors.New("Local authentication needs a user config path")
+	}
+
+	id, password := "", ""
+	if prop, found := props.Get(LOCAL_SECURITY_AUTHENTICATE_USER_PROPERTY); found {
+		id, _ = prop.Get().(string)
+	}
+	if prop, found := props.Get(LOCAL_SECURITY_AUTHENTICATE_PASSWORD_PROPERTY); found {
+		password, _ = prop.Get().(string)
+		prop.Set("") // do not keep the password in the properties
+	}
+	if id == "" || password == "" {
+		return errors.New("A user id and password are needed to authenticate")
+	}
+
+	user, err := authenticate.Authenticator.Authenticate(id, password)
+	if err != nil {
+		return err
+	}
+	session, err := New_LocalSession(user, LOCAL_SECURITY_SESSION_TTL)
+	if err != nil {
+		return err
+	}
+	if err := authenticate.Sessions.Save(session); err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{"user": session.User, "expires": session.Expires}).Info("Authenticated local session")
+
+	if prop, found := props.Get(LOCAL_SECURITY_AUTHENTICATE_TOKEN_PROPERTY); found {
+		prop.Set(session.Token)
+	}
+	if prop, found := props.Get(LOCAL_SECURITY_AUTHENTICATE_EXPIRES_PROPERTY); found {
+		prop.Set(session.Expires.Format(time.RFC3339))
+	}
+	return nil
+}
+
+/**
+ * Properties
+ */
+
+// Property for the id of the user to authenticate
+type LocalAuthenticateUserProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (user *LocalAuthenticateUserProperty) Id() string {
+	return LOCAL_SECURITY_AUTHENTICATE_USER_PROPERTY
+}
+
+// Label for the Property
+func (user *LocalAuthenticateUserProperty) Label() string {
+	return "User"
+}
+
+// Description for the Property
+func (user *LocalAuthenticateUserProperty) Description() string {
+	return "The id of the user to authenticate."
+}
+
+// Is the Property internal only
+func (user *LocalAuthenticateUserProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (user *LocalAuthenticateUserProperty) Copy() api_property.Property {
+	prop := &LocalAuthenticateUserProperty{}
+	prop.Set(user.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the password of the user to authenticate
+type LocalAuthenticatePasswordProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (password *LocalAuthenticatePasswordProperty) Id() string {
+	return LOCAL_SECURITY_AUTHENTICATE_PASSWORD_PROPERTY
+}
+
+// Label for the Property
+func (password *LocalAuthenticatePasswordProperty) Label() string {
+	return "Password"
+}
+
+// Description for the Property
+func (password *LocalAuthenticatePasswordProperty) Description() string {
+	return "The password of the user to authenticate."
+}
+
+// Is the Property internal only
+func (password *LocalAuthenticatePasswordProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (password *LocalAuthenticatePasswordProperty) Copy() api_property.Property {
+	prop := &LocalAuthenticatePasswordProperty{}
+	prop.Set(password.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the token of the started session
+type LocalAuthenticateTokenProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (token *LocalAuthenticateTokenProperty) Id() string {
+	return LOCAL_SECURITY_AUTHENTICATE_TOKEN_PROPERTY
+}
+
+// Label for the Property
+func (token *LocalAuthenticateTokenProperty) Label() string {
+	return "Session token"
+}
+
+// Description for the Property
+func (token *LocalAuthenticateTokenProperty) Description() string {
+	return "The token of the authenticated session."
+}
+
+// Is the Property internal only
+func (token *LocalAuthenticateTokenProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (token *LocalAuthenticateTokenProperty) Copy() api_property.Property {
+	prop := &LocalAuthenticateTokenProperty{}
+	prop.Set(token.Get())
+	return api_property.Property(prop)
+}
+
+// Property for the expiry time of the started session
+type LocalAuthenticateExpiresProperty struct {
+	api_property.StringProperty
+}
+
+// Id for the Property
+func (expires *LocalAuthenticateExpiresProperty) Id() string {
+	return LOCAL_SECURITY_AUTHENTICATE_EXPIRES_PROPERTY
+}
+
+// Label for the Property
+func (expires *LocalAuthenticateExpiresProperty) Label() string {
+	return "Session expiry"
+}
+
+// Description for the Property
+func (expires *LocalAuthenticateExpiresProperty) Description() string {
+	return "When the authenticated session expires, as an RFC3339 time."
+}
+
+// Is the Property internal only
+func (expires *LocalAuthenticateExpiresProperty) Usage() api_usage.Usage {
+	return api_property.Usage_Optional()
+}
+
+// Copy the property
+func (expires *LocalAuthenticateExpiresProperty) Copy() api_property.Property {
+	prop := &LocalAuthenticateExpiresProperty{}
+	prop.Set(expires.Get())
+	return api_property.Property(prop)
+}
@@ -0,0 +1,138 @@
+package local
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	api_security "github.com/wunderkraut/radi-api/operation/security"
+)
+
+// Credential users for session tests, which know only some user ids
+type localTestCredentialUsers []string
+
+// Get a user from the credentials by id
+func (users localTestCredentialUsers) CredentialUser(id string) (api_security.SecurityUser, error) {
+	for _, known := range users {
+		if known == id {
+			return api_security.New_CoreUserSecurityUser(&user.User{Username: id, Name: id}).SecurityUser(), nil
+		}
+	}
+	return nil, errors.New("no credentials for user " + id)
+}
+
+func TestLocalSessionMatches(t *testing.T) {
+	session := LocalSession{TokenHash: localSessionTokenHash("token")}
+
+	tests := []struct {
+		name    string
+		session LocalSession
+		token   string
+		matches bool
+	}{
+		{name: "matching token", session: session, token: "token", matches: true},
+		{name: "different token", session: session, token: "other", matches: false},
+		{name: "empty token", session: session, token: "", matches: false},
+		{name: "the hash as a token", session: session, token: session.TokenHash, matches: false},
+		{name: "no token hash", session: LocalSession{}, token: "token", matches: false},
+		{name: "no token hash or token", session: LocalSession{}, token: "", matches: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if matches := test.session.Matches(test.token); matches != test.matches {
+				t.Errorf("expected %v, got %v", test.matches, matches)
+			}
+		})
+	}
+}
+
+func TestLocalSessionStoreCurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "radi-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer os.Setenv(LOCAL_SECURITY_SESSION_TOKEN_ENV, os.Getenv(LOCAL_SECURITY_SESSION_TOKEN_ENV))
+
+	valid := LocalSession{TokenHash: localSessionTokenHash("token"), Expires: time.Now().Add(time.Hour), User: "jane"}
+	expired := valid
+	expired.Expires = time.Now().Add(-time.Minute)
+	noUser := valid
+	noUser.User = ""
+
+	tests := []struct {
+		name    string
+		session *LocalSession // nil for no session file
+		token   string
+		users   localTestCredentialUsers
+		current bool
+	}{
+		{name: "valid session", session: &valid, token: "token", users: localTestCredentialUsers{"jane"}, current: true},
+		{name: "no token given", session: &valid, token: "", users: localTestCredentialUsers{"jane"}},
+		{name: "different token", session: &valid, token: "other", users: localTestCredentialUsers{"jane"}},
+		{name: "no session file", session: nil, token: "token", users: localTestCredentialUsers{"jane"}},
+		{name: "expired session", session: &expired, token: "token", users: localTestCredentialUsers{"jane"}},
+		{name: "session without a user", session: &noUser, token: "token", users: localTestCredentialUsers{"jane"}},
+		{name: "user removed from credentials", session: &valid, token: "token", users: localTestCredentialUsers{"john"}},
+		{name: "no credentials", session: &valid, token: "token", users: nil},
+	}
+
+	for index, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &LocalSessionStore{path: path.Join(dir, strconv.Itoa(index), LOCAL_SECURITY_SESSION_FILE)}
+			if test.users != nil { // a nil list would still be a non nil interface
+				store.users = test.users
+			}
+			if test.session != nil {
+				if err := store.Save(*test.session); err != nil {
+					t.Fatal(err)
+				}
+			}
+			os.Setenv(LOCAL_SECURITY_SESSION_TOKEN_ENV, test.token)
+
+			_, currentUser, current := store.Current()
+			if current != test.current {
+				t.Fatalf("expected current %v, got %v", test.current, current)
+			}
+			if current && currentUser.Id() != test.session.User {
+				t.Errorf("expected user %s, got %s", test.session.User, currentUser.Id())
+			}
+		})
+	}
+}
+
+func TestLocalSessionSaveKeepsOnlyTheTokenHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "radi-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	session, err := New_LocalSession(api_security.New_CoreUserSecurityUser(&user.User{Username: "jane"}).SecurityUser(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &LocalSessionStore{path: path.Join(dir, LOCAL_SECURITY_SESSION_FILE)}
+	if err := store.Save(session); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Token == "" || string(contents) == "" {
+		t.Fatal("expected a session token and a saved session")
+	}
+	if strings.Contains(string(contents), session.Token) {
+		t.Fatal("the saved session contains the session token")
+	}
+}
@@ -1,6 +1,8 @@
 package local
 
 import (
+	"sync"
+
 	api_operation "github.com/wunderkraut/radi-api/operation"
 	api_setting "github.com/wunderkraut/radi-api/operation/setting"
 
@@ -11,6 +13,9 @@ import (
 type LocalHandler_Setting struct {
 	LocalHandler_Base
 	LocalHandler_ConfigWrapperBase
+
+	wrapper     *handler_configwrapper.BaseSettingConfigWrapperYmlOperation
+	wrapperOnce sync.Once
 }
 
 // Identify the handler
@@ -22,17 +27,51 @@ func (handler *LocalHandler_Setting) Id() string {
 func (handler *LocalHandler_Setting) Operations() api_operation.Operations {
 	ops := api_operation.New_SimpleOperations()
 
-	// Make a wrapper for the Settings Config interpretation, based on itnerpreting YML settings
-	wrapper := handler_configwrapper.SettingsConfigWrapper(handler_configwrapper.New_BaseSettingConfigWrapperYmlOperation(handler.ConfigWrapper()))
+	// All operations share one settings wrapper, so that they see the same loaded values
+	ymlWrapper := handler.settingsYml()
+	wrapper := handler_configwrapper.SettingsConfigWrapper(ymlWrapper)
+
+	// Setting changes are recorded in a local history log, if there is a user path for it
+	var audit handler_configwrapper.SettingAuditLog
+	if localAudit := New_LocalSettingAuditLog(handler.LocalAPISettings()); localAudit != nil {
+		audit = localAudit.SettingAuditLog()
+	}
 
 	// Now we can add config operations that use that Base class
 	ops.Add(api_operation.Operation(&handler_configwrapper.SettingConfigWrapperGetOperation{Wrapper: wrapper}))
-	ops.Add(api_operation.Operation(&handler_configwrapper.SettingConfigWrapperSetOperation{Wrapper: wrapper}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SettingConfigWrapperSetOperation{Wrapper: wrapper, Audit: audit, Users: handler.UserSource()}))
 	ops.Add(api_operation.Operation(&handler_configwrapper.SettingConfigWrapperListOperation{Wrapper: wrapper}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SettingConfigWrapperExportOperation{Wrapper: wrapper}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SettingConfigWrapperImportOperation{Wrapper: wrapper, Audit: audit, Users: handler.UserSource()}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SettingConfigWrapperHistoryOperation{Audit: audit}))
+	ops.Add(api_operation.Operation(&handler_configwrapper.SettingConfigWrapperLintOperation{Wrapper: ymlWrapper}))
 
 	return ops.Operations()
 }
 
+// Get the shared wrapper for the Settings Config interpretation, based on interpreting YML settings
+func (handler *LocalHandler_Setting) SettingsConfigWrapper() handler_configwrapper.SettingsConfigWrapper {
+	return handler_configwrapper.SettingsConfigWrapper(handler.settingsYml())
+}
+
+// Get the shared yml settings wrapper, which also lints and publishes changes
+func (handler *LocalHandler_Setting) settingsYml() *handler_configwrapper.BaseSettingConfigWrapperYmlOperation {
+	handler.wrapperOnce.Do(func() {
+		handler.wrapper = handler_configwrapper.New_BaseSettingConfigWrapperYmlOperation(handler.ConfigWrapper())
+		// setting change subscriptions end with the API context
+		if settings := handler.LocalAPISettings(); settings != nil && settings.Context != nil {
+			handler.wrapper.SetContext(settings.Context)
+		}
+	})
+	return handler.wrapper
+}
+
+// Get a source for the current user, used to record who changed settings
+func (handler *LocalHandler_Setting) UserSource() handler_configwrapper.SettingAuditUserSource {
+	securityWrapper := handler_configwrapper.New_SecurityConfigWrapperYml(handler.ConfigWrapper()).SecurityConfigWrapper()
+	return handler_configwrapper.SettingAuditUserSource(New_LocalUserSource(handler.LocalAPISettings(), securityWrapper))
+}
+
 // Make ConfigWrapper
 func (handler *LocalHandler_Setting) SettingWrapper() api_setting.SettingWrapper {
 	return api_setting.New_SimpleSettingWrapper(handler.Operations())
@@ -0,0 +1,63 @@
+package local
+
+import (
+	"encoding/json"
+	"path"
+
+	handler_configwrapper "github.com/wunderkraut/radi-handlers/configwrapper"
+)
+
+const (
+	// The config path key in which local logs are kept
+	LOCAL_LOG_PATH_SCOPE = "user"
+	// File name for the settings change history, in the user config path
+	LOCAL_SETTING_HISTORY_FILE = "settings-history.jsonl"
+)
+
+/**
+ * A settings audit log, kept as a local JSON lines
+ * file in the user config path.
+ */
+
+// Constructor for LocalSettingAuditLog, which returns nil if there is no user config path
+func New_LocalSettingAuditLog(settings *LocalAPISettings) *LocalSettingAuditLog {
+	if settings.ConfigPaths == nil {
+		return nil
+	}
+	userPath, found := settings.ConfigPaths.Get(LOCAL_LOG_PATH_SCOPE)
+	if !found {
+		return nil
+	}
+	return &LocalSettingAuditLog{
+		file: jsonlFile{path: path.Join(userPath.PathString(), LOCAL_SETTING_HISTORY_FILE)},
+	}
+}
+
+// A settings audit log in a local JSON lines file
+type LocalSettingAuditLog struct {
+	file jsonlFile
+}
+
+// Convert this to a SettingAuditLog
+func (audit *LocalSettingAuditLog) SettingAuditLog() handler_configwrapper.SettingAuditLog {
+	return handler_configwrapper.SettingAuditLog(audit)
+}
+
+// Append a record to the log
+func (audit *LocalSettingAuditLog) Append(record handler_configwrapper.SettingAuditRecord) error {
+	return audit.file.Append(record)
+}
+
+// Read all records from the log
+func (audit *LocalSettingAuditLog) Records() ([]handler_configwrapper.SettingAuditRecord, error) {
+	records := []handler_configwrapper.SettingAuditRecord{}
+	err := audit.file.Each(func(line []byte) error {
+		record := handler_configwrapper.SettingAuditRecord{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
@@ -6,6 +6,8 @@ import (
 	api_operation "github.com/wunderkraut/radi-api/operation"
 	api_monitor "github.com/wunderkraut/radi-api/operation/monitor"
 	api_result "github.com/wunderkraut/radi-api/result"
+
+	handler_configwrapper "github.com/wunderkraut/radi-handlers/configwrapper"
 )
 
 /**
@@ -33,6 +35,21 @@ func (builder *NullBuilder) SetAPI(parent api_api.API) {
 	// do nothing, who cares
 }
 
+// Publish the schema for null project component Settings, which has no settings
+func (builder *NullBuilder) ProjectSettingsSchema() handler_configwrapper.ProjectSettingsSchema {
+	return handler_configwrapper.ProjectSettingsSchema{}
+}
+
+// List the implementations that the null builder provides
+func (builder *NullBuilder) KnownImplementations() []string {
+	return []string{"config", "setting", "command", "document", "monitor", "orchestrate", "security"}
+}
+
+// The null builder adds operations without handlers
+func (builder *NullBuilder) ImplementationHandlers(implementation string) []string {
+	return []string{}
+}
+
 // Initialize and activate the Handler
 func (builder *NullBuilder) Activate(implementations api_builder.Implementations, settingsProvider api_builder.SettingsProvider) api_result.Result {
 	for _, implementation := range implementations.Order() {
@@ -51,6 +68,14 @@ func (builder *NullBuilder) Activate(implementations api_builder.Implementations
 	return api_result.MakeSuccessfulResult()
 }
 
+// Activate implementations in a fresh builder, and list the operations that
+// they add, for planning (null activation has no side effects)
+func (builder *NullBuilder) DryActivate(implementations api_builder.Implementations, settingsProvider api_builder.SettingsProvider) ([]string, error) {
+	dryRun := New_NullBuilder()
+	dryRun.Activate(implementations, settingsProvider)
+	return dryRun.Operations().Order(), nil
+}
+
 // Validate the builder after Activation is complete
 func (builder *NullBuilder) Validate() api_result.Result {
 	return api_result.MakeSuccessfulResult()